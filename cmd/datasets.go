@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// defaultDataset is loaded into every new session when set.
+var defaultDataset string
+
+type dataset struct {
+	name string
+	desc string
+	cmds func() [][]string
+}
+
+var datasets = []dataset{
+	{"cities", "major world cities as points in 'cities'", citiesDataset},
+	{"fleet", "delivery vehicles around Phoenix in 'fleet'", fleetDataset},
+	{"regions", "polygon regions around Phoenix in 'regions'", regionsDataset},
+}
+
+func findDataset(name string) *dataset {
+	for i := range datasets {
+		if strings.EqualFold(datasets[i].name, name) {
+			return &datasets[i]
+		}
+	}
+	return nil
+}
+
+// loadDataset issues the dataset commands directly to the tile38-server
+// listening on port, which persists them in the session's AOF.
+func loadDataset(port int, ds *dataset) (int, error) {
+	rc, err := dialTile38(port)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	cmds := ds.cmds()
+	for _, args := range cmds {
+		if _, err := rc.Do(args...); err != nil {
+			return 0, err
+		}
+	}
+	return len(cmds), nil
+}
+
+func loadDefaultDataset(id string, port int) {
+	ds := findDataset(defaultDataset)
+	if ds == nil {
+		return
+	}
+	n, err := loadDataset(port, ds)
+	if err != nil {
+		log.Printf("error: %s", err.Error())
+		return
+	}
+	log.Printf("loaded dataset %s (%d objects) into %s", ds.name, n, id)
+}
+
+// loadCommand handles the ':load [name]' console command and returns the
+// reply that should be written back to the CLI.
+func loadCommand(port int, line string) string {
+	args := strings.Fields(line)
+	if len(args) < 2 {
+		var buf bytes.Buffer
+		buf.WriteString("Available datasets:\n")
+		for _, ds := range datasets {
+			fmt.Fprintf(&buf, "  %-8s %s\n", ds.name, ds.desc)
+		}
+		buf.WriteString("Type ':load <name>' to load a dataset.\n")
+		return buf.String()
+	}
+	ds := findDataset(args[1])
+	if ds == nil {
+		return fmt.Sprintf("(error) unknown dataset '%s'\n", args[1])
+	}
+	n, err := loadDataset(port, ds)
+	if err != nil {
+		return fmt.Sprintf("(error) %s\n", err.Error())
+	}
+	return fmt.Sprintf("Loaded %d objects from the '%s' dataset.\n", n, ds.name)
+}
+
+func citiesDataset() [][]string {
+	cities := []struct {
+		name     string
+		lat, lon float64
+	}{
+		{"amsterdam", 52.3676, 4.9041},
+		{"bangkok", 13.7563, 100.5018},
+		{"beijing", 39.9042, 116.4074},
+		{"berlin", 52.5200, 13.4050},
+		{"buenos_aires", -34.6037, -58.3816},
+		{"cairo", 30.0444, 31.2357},
+		{"cape_town", -33.9249, 18.4241},
+		{"chicago", 41.8781, -87.6298},
+		{"delhi", 28.7041, 77.1025},
+		{"dubai", 25.2048, 55.2708},
+		{"hong_kong", 22.3193, 114.1694},
+		{"istanbul", 41.0082, 28.9784},
+		{"lagos", 6.5244, 3.3792},
+		{"london", 51.5074, -0.1278},
+		{"los_angeles", 34.0522, -118.2437},
+		{"madrid", 40.4168, -3.7038},
+		{"mexico_city", 19.4326, -99.1332},
+		{"moscow", 55.7558, 37.6173},
+		{"mumbai", 19.0760, 72.8777},
+		{"new_york", 40.7128, -74.0060},
+		{"paris", 48.8566, 2.3522},
+		{"phoenix", 33.4484, -112.0740},
+		{"rome", 41.9028, 12.4964},
+		{"san_francisco", 37.7749, -122.4194},
+		{"sao_paulo", -23.5505, -46.6333},
+		{"seoul", 37.5665, 126.9780},
+		{"singapore", 1.3521, 103.8198},
+		{"sydney", -33.8688, 151.2093},
+		{"tokyo", 35.6762, 139.6503},
+		{"toronto", 43.6532, -79.3832},
+	}
+	var cmds [][]string
+	for _, c := range cities {
+		cmds = append(cmds, []string{"SET", "cities", c.name, "POINT", ftoa(c.lat), ftoa(c.lon)})
+	}
+	return cmds
+}
+
+// fleetCenter is the point that the fleet and regions datasets surround.
+var fleetCenter = struct{ lat, lon float64 }{33.4484, -112.0740}
+
+func fleetDataset() [][]string {
+	rnd := rand.New(rand.NewSource(38))
+	var cmds [][]string
+	for i := 1; i <= 50; i++ {
+		lat := fleetCenter.lat + (rnd.Float64()-0.5)*0.4
+		lon := fleetCenter.lon + (rnd.Float64()-0.5)*0.5
+		speed := rnd.Intn(65)
+		cmds = append(cmds, []string{"SET", "fleet", fmt.Sprintf("truck%d", i),
+			"FIELD", "speed", fmt.Sprintf("%d", speed),
+			"POINT", ftoa(lat), ftoa(lon)})
+	}
+	return cmds
+}
+
+func regionsDataset() [][]string {
+	regions := []struct {
+		name   string
+		coords [][2]float64 // lon, lat
+	}{
+		{"downtown", [][2]float64{
+			{-112.100, 33.430}, {-112.040, 33.430}, {-112.035, 33.455},
+			{-112.050, 33.470}, {-112.100, 33.470},
+		}},
+		{"airport", [][2]float64{
+			{-112.030, 33.420}, {-111.990, 33.420}, {-111.990, 33.445},
+			{-112.030, 33.445},
+		}},
+		{"tempe", [][2]float64{
+			{-111.970, 33.370}, {-111.900, 33.370}, {-111.895, 33.410},
+			{-111.920, 33.440}, {-111.970, 33.440},
+		}},
+		{"scottsdale", [][2]float64{
+			{-111.950, 33.470}, {-111.870, 33.470}, {-111.860, 33.520},
+			{-111.890, 33.550}, {-111.950, 33.550},
+		}},
+		{"glendale", [][2]float64{
+			{-112.240, 33.500}, {-112.160, 33.500}, {-112.160, 33.570},
+			{-112.210, 33.575}, {-112.240, 33.550},
+		}},
+	}
+	var cmds [][]string
+	for _, r := range regions {
+		var buf bytes.Buffer
+		buf.WriteString(`{"type":"Polygon","coordinates":[[`)
+		for i, c := range append(r.coords, r.coords[0]) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString("[" + ftoa(c[0]) + "," + ftoa(c[1]) + "]")
+		}
+		buf.WriteString(`]]}`)
+		cmds = append(cmds, []string{"SET", "regions", r.name, "OBJECT", buf.String()})
+	}
+	return cmds
+}
+
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
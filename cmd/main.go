@@ -10,10 +10,16 @@ import (
 func main() {
 	var port int
 	flag.IntVar(&port, "p", 8000, "server port")
+	flag.StringVar(&defaultDataset, "dataset", "", "dataset to load into new sessions")
 	flag.Parse()
+	if defaultDataset != "" && findDataset(defaultDataset) == nil {
+		log.Fatalf("unknown dataset '%s'", defaultDataset)
+	}
 	log.Printf("Starting server on port %d", port)
 	http.HandleFunc("/tile38-server/", tile38Server)
 	http.HandleFunc("/tile38-cli/", tile38CLI)
+	http.HandleFunc("/tile38-watch/", tile38Watch)
+	http.HandleFunc("/tile38-keys/", tile38Keys)
 	http.HandleFunc("/", canvas)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), nil))
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// respConn is a minimal RESP client used by the demo server to talk directly
+// to a session's tile38-server, bypassing the tile38-cli process.
+type respConn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// respError is an error reply sent by the server.
+type respError string
+
+func (err respError) Error() string {
+	return string(err)
+}
+
+func dialTile38(port int) (*respConn, error) {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second*5)
+	if err != nil {
+		return nil, err
+	}
+	return &respConn{conn: conn, rd: bufio.NewReader(conn)}, nil
+}
+
+func (c *respConn) Close() error {
+	return c.conn.Close()
+}
+
+// Do sends a single command and returns the reply as a string. Error replies
+// are returned as errors.
+func (c *respConn) Do(args ...string) (string, error) {
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return "", err
+	}
+	return c.readReply()
+}
+
+func (c *respConn) readLine() (string, error) {
+	line, err := c.rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return "", errors.New("invalid reply")
+	}
+	return line[:len(line)-2], nil
+}
+
+func (c *respConn) readReply() (string, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return "", err
+	}
+	switch line[0] {
+	default:
+		return "", errors.New("invalid reply")
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", respError(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", err
+		}
+		if n < 0 {
+			return "", nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.rd, data); err != nil {
+			return "", err
+		}
+		return string(data[:n]), nil
+	case '*':
+		// arrays are not used by the demo, but the elements must still be
+		// consumed to keep the connection in sync.
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", err
+		}
+		for i := 0; i < n; i++ {
+			if _, err := c.readReply(); err != nil {
+				if _, ok := err.(respError); !ok {
+					return "", err
+				}
+			}
+		}
+		return "", errors.New("unexpected array reply")
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Per-session simulator limits.
+const (
+	maxSimObjects = 200
+	maxSimRate    = 50 // updates per second
+	defSimObjects = 20
+	defSimRate    = 10
+	simKey        = "sim"
+	simTimeout    = time.Second * 2 // for each update sent to tile38
+)
+
+// simRoutes are predefined loops around the fleet center. Each point is a
+// lat, lon pair.
+var simRoutes = [][][2]float64{
+	{ // downtown loop
+		{33.430, -112.100}, {33.430, -112.040}, {33.455, -112.035},
+		{33.470, -112.050}, {33.470, -112.100},
+	},
+	{ // downtown to the airport and tempe
+		{33.448, -112.074}, {33.435, -112.010}, {33.425, -111.940},
+		{33.390, -111.930}, {33.435, -112.010},
+	},
+	{ // scottsdale to glendale
+		{33.510, -111.900}, {33.490, -111.990}, {33.470, -112.074},
+		{33.530, -112.190}, {33.490, -111.990},
+	},
+}
+
+type simObject struct {
+	id      string
+	lat     float64
+	lon     float64
+	heading float64 // radians, random mode only
+	speed   float64 // degrees per second
+	route   int
+	leg     int
+	updated time.Time
+}
+
+type simulator struct {
+	id   string
+	mode string
+	rate int
+	objs []*simObject
+	rnd  *rand.Rand
+	stop chan struct{}
+	done chan struct{}
+}
+
+var simmu sync.Mutex
+var sims = make(map[string]*simulator)
+
+// simCommand handles the ':sim' console command and returns the reply that
+// should be written back to the CLI.
+func simCommand(id string, port int, line string) string {
+	args := strings.Fields(line)
+	if len(args) < 2 {
+		args = append(args, "status")
+	}
+	switch strings.ToLower(args[1]) {
+	default:
+		return "Usage: :sim start [random|routes] [count [rate]], :sim stop, :sim status\n"
+	case "status":
+		simmu.Lock()
+		sim := sims[id]
+		simmu.Unlock()
+		if sim == nil {
+			return "Simulator is not running.\n"
+		}
+		return fmt.Sprintf("Simulator is moving %d objects in '%s' at %d updates/sec (%s).\n",
+			len(sim.objs), simKey, sim.rate, sim.mode)
+	case "stop":
+		if !stopSimulator(id) {
+			return "Simulator is not running.\n"
+		}
+		return "Simulator stopped.\n"
+	case "start":
+		// the mode may come anywhere, and the numbers are the count
+		// and then the rate
+		count, rate, mode := defSimObjects, defSimRate, "random"
+		var nums int
+		for _, arg := range args[2:] {
+			if lower := strings.ToLower(arg); lower == "random" || lower == "routes" {
+				mode = lower
+				continue
+			}
+			n, err := strconv.Atoi(arg)
+			if err != nil || n <= 0 {
+				return fmt.Sprintf("(error) invalid argument '%s'\n", arg)
+			}
+			switch nums {
+			case 0:
+				count = n
+			case 1:
+				rate = n
+			default:
+				return fmt.Sprintf("(error) invalid argument '%s'\n", arg)
+			}
+			nums++
+		}
+		if count > maxSimObjects {
+			return fmt.Sprintf("(error) count must be %d or less\n", maxSimObjects)
+		}
+		if rate > maxSimRate {
+			return fmt.Sprintf("(error) rate must be %d or less\n", maxSimRate)
+		}
+		if err := startSimulator(id, port, count, rate, mode); err != nil {
+			return fmt.Sprintf("(error) %s\n", err.Error())
+		}
+		return fmt.Sprintf("Simulator started: %d objects in '%s' at %d updates/sec (%s).\n",
+			count, simKey, rate, mode)
+	}
+}
+
+func startSimulator(id string, port int, count, rate int, mode string) error {
+	simmu.Lock()
+	running := sims[id] != nil
+	simmu.Unlock()
+	if running {
+		return fmt.Errorf("simulator already running")
+	}
+	// dial without the lock, which would hold up every other session
+	rc, err := dialTile38(port)
+	if err != nil {
+		return err
+	}
+	sim := &simulator{
+		id:   id,
+		mode: mode,
+		rate: rate,
+		rnd:  rand.New(rand.NewSource(time.Now().UnixNano())),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	now := time.Now()
+	for i := 0; i < count; i++ {
+		obj := &simObject{
+			id:      fmt.Sprintf("car%d", i+1),
+			speed:   0.0001 + sim.rnd.Float64()*0.0003,
+			updated: now,
+		}
+		if mode == "routes" {
+			obj.route = i % len(simRoutes)
+			route := simRoutes[obj.route]
+			obj.leg = sim.rnd.Intn(len(route))
+			obj.lat, obj.lon = route[obj.leg][0], route[obj.leg][1]
+		} else {
+			obj.lat = fleetCenter.lat + (sim.rnd.Float64()-0.5)*0.3
+			obj.lon = fleetCenter.lon + (sim.rnd.Float64()-0.5)*0.4
+			obj.heading = sim.rnd.Float64() * math.Pi * 2
+		}
+		sim.objs = append(sim.objs, obj)
+	}
+	simmu.Lock()
+	defer simmu.Unlock()
+	if sims[id] != nil {
+		// another one was started while dialing
+		rc.Close()
+		return fmt.Errorf("simulator already running")
+	}
+	sims[id] = sim
+	go sim.run(rc)
+	log.Printf("started simulator %s", id)
+	return nil
+}
+
+// stopSimulator stops the simulator for a session and waits for it to exit.
+// Returns false when no simulator was running.
+func stopSimulator(id string) bool {
+	simmu.Lock()
+	sim := sims[id]
+	delete(sims, id)
+	simmu.Unlock()
+	if sim == nil {
+		return false
+	}
+	close(sim.stop)
+	<-sim.done
+	log.Printf("stopped simulator %s", id)
+	return true
+}
+
+func (sim *simulator) run(rc *respConn) {
+	defer func() {
+		rc.Close()
+		close(sim.done)
+	}()
+	ticker := time.NewTicker(time.Second / time.Duration(sim.rate))
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		select {
+		case <-sim.stop:
+			return
+		case now := <-ticker.C:
+			obj := sim.objs[i%len(sim.objs)]
+			sim.move(obj, now.Sub(obj.updated).Seconds())
+			obj.updated = now
+			// a stalled server must not keep stopSimulator waiting
+			rc.conn.SetDeadline(time.Now().Add(simTimeout))
+			_, err := rc.Do("SET", simKey, obj.id, "POINT", ftoa(obj.lat), ftoa(obj.lon))
+			if err != nil {
+				log.Printf("error: %s", err.Error())
+				simmu.Lock()
+				if sims[sim.id] == sim {
+					delete(sims, sim.id)
+				}
+				simmu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+func (sim *simulator) move(obj *simObject, secs float64) {
+	dist := obj.speed * secs
+	if sim.mode == "routes" {
+		route := simRoutes[obj.route]
+		for dist > 0 {
+			next := route[(obj.leg+1)%len(route)]
+			dlat, dlon := next[0]-obj.lat, next[1]-obj.lon
+			d := math.Hypot(dlat, dlon)
+			if d <= dist {
+				obj.lat, obj.lon = next[0], next[1]
+				obj.leg = (obj.leg + 1) % len(route)
+				dist -= d
+				continue
+			}
+			obj.lat += dlat / d * dist
+			obj.lon += dlon / d * dist
+			dist = 0
+		}
+		return
+	}
+	// random mode wanders and turns back toward the center when it strays
+	// too far away.
+	obj.heading += (sim.rnd.Float64() - 0.5) * 0.6
+	if math.Hypot(obj.lat-fleetCenter.lat, obj.lon-fleetCenter.lon) > 0.25 {
+		obj.heading = math.Atan2(fleetCenter.lat-obj.lat, fleetCenter.lon-obj.lon)
+	}
+	obj.lat += math.Sin(obj.heading) * dist
+	obj.lon += math.Cos(obj.heading) * dist
+}
@@ -36,6 +36,15 @@ func tile38CLI(w http.ResponseWriter, r *http.Request) {
 
 	var wrmu sync.Mutex
 
+	// write sends a message to the owner and mirrors it to any spectators.
+	write := func(msg []byte) error {
+		wrmu.Lock()
+		err := conn.WriteMessage(websocket.TextMessage, msg)
+		wrmu.Unlock()
+		broadcast(id, msg)
+		return err
+	}
+
 	cmd := exec.Command("tile38-cli", "-p", fmt.Sprintf("%d", port), "--noprompt", "--tty")
 	erd, err := cmd.StderrPipe()
 	if err != nil {
@@ -58,9 +67,7 @@ func tile38CLI(w http.ResponseWriter, r *http.Request) {
 				log.Printf("error: %s", err.Error())
 				return
 			}
-			wrmu.Lock()
-			err = conn.WriteMessage(websocket.TextMessage, append([]byte(`stderr: `), line...))
-			wrmu.Unlock()
+			err = write(append([]byte(`stderr: `), line...))
 			if err != nil {
 				log.Printf("error: %s", err.Error())
 				return
@@ -88,9 +95,7 @@ func tile38CLI(w http.ResponseWriter, r *http.Request) {
 				log.Printf("error: %s", err.Error())
 				return
 			}
-			wrmu.Lock()
-			err = conn.WriteMessage(websocket.TextMessage, append([]byte(`stdout: `), line...))
-			wrmu.Unlock()
+			err = write(append([]byte(`stdout: `), line...))
 			if err != nil {
 				log.Printf("error: %s", err.Error())
 				return
@@ -123,10 +128,17 @@ func tile38CLI(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			s := string(msg)
+			broadcast(id, append([]byte(`input: `), msg...))
 			if strings.HasPrefix(strings.ToLower(s), "follow ") {
-				wrmu.Lock()
-				conn.WriteMessage(websocket.TextMessage, append([]byte(`stdout: `), []byte("(error) Sorry but FOLLOW is disabled.\n")...))
-				wrmu.Unlock()
+				write(append([]byte(`stdout: `), []byte("(error) Sorry but FOLLOW is disabled.\n")...))
+				continue
+			}
+			if reply, ok := serverCommand(id, port, s); ok {
+				err = write(append([]byte(`stdout: `), []byte(reply)...))
+				if err != nil {
+					log.Printf("error: %s", err.Error())
+					return
+				}
 				continue
 			}
 			_, err = fmt.Fprintf(iwr, "%s\n", s)
@@ -149,3 +161,15 @@ func tile38CLI(w http.ResponseWriter, r *http.Request) {
 	}
 
 }
+
+// serverCommand runs the console commands that are handled by the demo
+// server rather than tile38-cli.
+func serverCommand(id string, port int, line string) (string, bool) {
+	switch strings.ToLower(strings.SplitN(line, " ", 2)[0]) {
+	case ":load":
+		return loadCommand(port, line), true
+	case ":sim":
+		return simCommand(id, port, line), true
+	}
+	return "", false
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits on what is returned to the console for completion.
+const (
+	maxCompleteKeys = 100
+	maxCompleteIDs  = 200
+	keysTimeout     = time.Second * 5 // for all the queries of a request
+)
+
+// tile38Keys returns the collection keys of a session and some of the ids in
+// each, as a JSON object of key to ids. The console uses it for completion.
+func tile38Keys(w http.ResponseWriter, r *http.Request) {
+	var id string
+	idp := strings.Split(r.URL.Path, "/")
+	if len(idp) >= 3 {
+		id = idp[2]
+	}
+	shmu.Lock()
+	port := idmap[id]
+	shmu.Unlock()
+	if port == 0 {
+		http.Error(w, "invalid id", http.StatusNotFound)
+		return
+	}
+	keys, err := sessionKeys(port)
+	if err != nil {
+		log.Printf("keys: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// sessionKeys queries the keys and ids of a session's tile38-server.
+func sessionKeys(port int) (map[string][]string, error) {
+	rc, err := dialTile38(port)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	// a stalled server must not hold up the request forever
+	rc.conn.SetDeadline(time.Now().Add(keysTimeout))
+	if _, err := rc.Do("OUTPUT", "json"); err != nil {
+		return nil, err
+	}
+	var res struct {
+		Keys []string `json:"keys"`
+		IDs  []string `json:"ids"`
+	}
+	reply, err := rc.Do("KEYS", "*")
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(reply), &res); err != nil {
+		return nil, err
+	}
+	keys := res.Keys
+	if len(keys) > maxCompleteKeys {
+		keys = keys[:maxCompleteKeys]
+	}
+	all := make(map[string][]string)
+	for _, key := range keys {
+		reply, err := rc.Do("SCAN", key, "LIMIT", strconv.Itoa(maxCompleteIDs), "IDS")
+		if err != nil {
+			return nil, err
+		}
+		res.IDs = nil
+		if err := json.Unmarshal([]byte(reply), &res); err != nil {
+			return nil, err
+		}
+		all[key] = res.IDs
+	}
+	return all, nil
+}
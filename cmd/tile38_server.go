@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
@@ -39,9 +40,18 @@ var upgrader = websocket.Upgrader{
 var shmu sync.Mutex
 var idmap = make(map[string]int)
 
+func newSessionID() (string, error) {
+	rb := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, rb); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(rb), nil
+}
+
 func tile38Server(w http.ResponseWriter, r *http.Request) {
 	var invalidid string
 	var id string
+	var fresh bool
 	idp := strings.Split(r.URL.Path, "/")
 	if len(idp) >= 3 {
 		id = idp[2]
@@ -55,12 +65,13 @@ func tile38Server(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if id == "" {
-		rb := make([]byte, 16)
-		if _, err := io.ReadFull(rand.Reader, rb); err != nil {
+		var err error
+		id, err = newSessionID()
+		if err != nil {
 			log.Print(err)
 			return
 		}
-		id = hex.EncodeToString(rb)
+		fresh = true
 	}
 
 	var wrmu sync.Mutex
@@ -77,11 +88,81 @@ func tile38Server(w http.ResponseWriter, r *http.Request) {
 		}
 
 	}
-	if err := conn.WriteMessage(websocket.TextMessage, []byte("id: "+id)); err != nil {
-		log.Print(err)
-		return
+
+	// proc is the running tile38-server process. It's guarded by wrmu along
+	// with the reset and closed flags.
+	var proc *exec.Cmd
+	var reset, closed bool
+	go func() {
+		defer func() {
+			conn.Close()
+			wrmu.Lock()
+			closed = true
+			if proc != nil {
+				proc.Process.Kill()
+			}
+			wrmu.Unlock()
+		}()
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				log.Printf("error: %s", err.Error())
+				return
+			}
+			if string(msg) == "reset" {
+				// killing the process ends the current run, and the loop
+				// below starts a new session in its place.
+				wrmu.Lock()
+				if proc != nil && !reset {
+					reset = true
+					proc.Process.Kill()
+				}
+				wrmu.Unlock()
+			}
+		}
+	}()
+
+	for {
+		wrmu.Lock()
+		err := conn.WriteMessage(websocket.TextMessage, []byte("id: "+id))
+		if err == nil {
+			err = conn.WriteMessage(websocket.TextMessage, []byte("share: "+shareID(id)))
+		}
+		wrmu.Unlock()
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		runTile38Server(conn, &wrmu, id, fresh, func(cmd *exec.Cmd) bool {
+			proc = cmd
+			return !closed
+		})
+		wrmu.Lock()
+		again := reset
+		reset = false
+		proc = nil
+		wrmu.Unlock()
+		if !again {
+			return
+		}
+		if err := os.RemoveAll(path.Join("data", id)); err != nil {
+			log.Printf("error: %s", err.Error())
+		}
+		log.Printf("reset tile38-server %s", id)
+		id, err = newSessionID()
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		fresh = true
 	}
+}
 
+// runTile38Server starts a tile38-server for the session and streams its
+// output to conn until the process exits. The started callback is called
+// with wrmu held once the process is running, and should return false if
+// the process must be stopped right away.
+func runTile38Server(conn *websocket.Conn, wrmu *sync.Mutex, id string, fresh bool, started func(cmd *exec.Cmd) bool) {
 	shmu.Lock()
 	port := nextTile38Port()
 	for i := 0; i < 50000; i++ {
@@ -99,44 +180,56 @@ func tile38Server(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if idmap[id] != 0 {
+		wrmu.Lock()
 		if err := conn.WriteMessage(websocket.TextMessage, []byte("err: server already started")); err != nil {
 			log.Print(err)
 		}
+		wrmu.Unlock()
 		shmu.Unlock()
 		return
 	}
 	cmd := exec.Command("tile38-server", "-vv", "-p", fmt.Sprintf("%d", port), "-d", path.Join("data", id))
-	erd, err := cmd.StderrPipe()
-	if err != nil {
-		shmu.Unlock()
-		log.Printf("error: %s", err.Error())
-		return
+	var ready sync.Once
+	checkReady := func(line []byte) {
+		if fresh && defaultDataset != "" && bytes.Contains(line, []byte("server is now ready to accept connections")) {
+			ready.Do(func() {
+				go loadDefaultDataset(id, port)
+			})
+		}
 	}
-	defer erd.Close()
-	go func() {
-		defer func() {
-			conn.Close()
-			wrmu.Lock()
-			cmd.Process.Kill()
-			wrmu.Unlock()
-		}()
-		rd := bufio.NewReader(erd)
+	// stream copies process output to conn. The process exiting is not an
+	// error here, but failing to write to conn stops the process.
+	stream := func(rd io.Reader, prefix string) {
+		brd := bufio.NewReader(rd)
 		for {
-			line, err := rd.ReadBytes('\n')
+			line, err := brd.ReadBytes('\n')
 			if err != nil {
-				log.Printf("error: %s", err.Error())
+				if err != io.EOF {
+					log.Printf("error: %s", err.Error())
+				}
 				return
 			}
+			checkReady(line)
 			wrmu.Lock()
-			err = conn.WriteMessage(websocket.TextMessage, append([]byte(`stderr: `), line...))
+			err = conn.WriteMessage(websocket.TextMessage, append([]byte(prefix), line...))
+			if err != nil {
+				cmd.Process.Kill()
+			}
 			wrmu.Unlock()
 			if err != nil {
 				log.Printf("error: %s", err.Error())
+				conn.Close()
 				return
 			}
 		}
-	}()
-
+	}
+	erd, err := cmd.StderrPipe()
+	if err != nil {
+		shmu.Unlock()
+		log.Printf("error: %s", err.Error())
+		return
+	}
+	defer erd.Close()
 	ord, err := cmd.StdoutPipe()
 	if err != nil {
 		shmu.Unlock()
@@ -144,54 +237,26 @@ func tile38Server(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer ord.Close()
-	go func() {
-		defer func() {
-			conn.Close()
-			wrmu.Lock()
-			cmd.Process.Kill()
-			wrmu.Unlock()
-		}()
-		rd := bufio.NewReader(ord)
-		for {
-			line, err := rd.ReadBytes('\n')
-			if err != nil {
-				log.Printf("error: %s", err.Error())
-				return
-			}
-			wrmu.Lock()
-			err = conn.WriteMessage(websocket.TextMessage, append([]byte(`stdout: `), line...))
-			wrmu.Unlock()
-			if err != nil {
-				log.Printf("error: %s", err.Error())
-				return
-			}
-		}
-	}()
-	go func() {
-		defer func() {
-			conn.Close()
-			wrmu.Lock()
-			cmd.Process.Kill()
-			wrmu.Unlock()
-		}()
-		for {
-			_, _, err := conn.ReadMessage()
-			if err != nil {
-				log.Printf("error: %s", err.Error())
-				return
-			}
-		}
-	}()
 	if err := cmd.Start(); err != nil {
 		shmu.Unlock()
 		log.Printf("error: %s", err.Error())
 		return
 	}
+	go stream(erd, `stderr: `)
+	go stream(ord, `stdout: `)
 	idmap[id] = port
 	shmu.Unlock()
 
+	wrmu.Lock()
+	if !started(cmd) {
+		cmd.Process.Kill()
+	}
+	wrmu.Unlock()
+
 	log.Printf("started tile38-server %s", id)
 	defer func() {
+		stopSimulator(id)
+		closeSpectators(id)
 		shmu.Lock()
 		delete(idmap, id)
 		shmu.Unlock()
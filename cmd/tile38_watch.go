@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// spectator is a read-only websocket that mirrors the CLI stream of a
+// session. Messages are queued so a slow spectator never blocks the owner.
+type spectator struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+var watchmu sync.Mutex
+var watchers = make(map[string]map[*spectator]bool)
+
+// shareID returns the public id of a session. It can't be turned back into
+// the session id, so a shared link doesn't grant write access.
+func shareID(id string) string {
+	sum := sha256.Sum256([]byte("share:" + id))
+	return hex.EncodeToString(sum[:16])
+}
+
+// broadcast sends a CLI message to every spectator of a session.
+func broadcast(id string, msg []byte) {
+	watchmu.Lock()
+	defer watchmu.Unlock()
+	for s := range watchers[id] {
+		select {
+		case s.send <- msg:
+		default:
+			// too far behind, drop the spectator
+			delete(watchers[id], s)
+			close(s.send)
+		}
+	}
+}
+
+// closeSpectators disconnects all spectators of a session.
+func closeSpectators(id string) {
+	watchmu.Lock()
+	defer watchmu.Unlock()
+	for s := range watchers[id] {
+		close(s.send)
+	}
+	delete(watchers, id)
+}
+
+func tile38Watch(w http.ResponseWriter, r *http.Request) {
+	var sid string
+	idp := strings.Split(r.URL.Path, "/")
+	if len(idp) >= 3 {
+		sid = idp[2]
+	}
+
+	var id string
+	shmu.Lock()
+	for sessid := range idmap {
+		if shareID(sessid) == sid {
+			id = sessid
+			break
+		}
+	}
+	shmu.Unlock()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close()
+	if id == "" {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("err: session not found")); err != nil {
+			log.Print(err)
+		}
+		return
+	}
+
+	s := &spectator{conn: conn, send: make(chan []byte, 256)}
+	watchmu.Lock()
+	if watchers[id] == nil {
+		watchers[id] = make(map[*spectator]bool)
+	}
+	watchers[id][s] = true
+	watchmu.Unlock()
+
+	go func() {
+		defer func() {
+			conn.Close()
+			watchmu.Lock()
+			if watchers[id][s] {
+				delete(watchers[id], s)
+				close(s.send)
+			}
+			watchmu.Unlock()
+		}()
+		// spectators are read-only, incoming messages are ignored
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	log.Printf("started spectator %s", id)
+	defer func() {
+		log.Printf("stopped spectator %s", id)
+	}()
+	for msg := range s.send {
+		if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			log.Printf("error: %s", err.Error())
+			return
+		}
+	}
+}
@@ -9,7 +9,6 @@ func main() {
 	sheet := js.Global.Get("document").Call("createElement", "style")
 	sheet.Set("innerHTML",
 		`html, body { 
-			background: black; 
 			padding:0; 
 			margin:0; 
 			border:0; 
@@ -0,0 +1,237 @@
+package console
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/tile38/try/terminal"
+)
+
+// cmdPrefix marks a line as a console command rather than a Tile38 command.
+const cmdPrefix = ":"
+
+type command struct {
+	name   string
+	args   string
+	help   string
+	remote bool     // forwarded to the demo server
+	values []string // completions for the first argument
+	fn     func(c *Console, args []string)
+}
+
+func (c *Console) registerCommands() {
+	c.commands = make(map[string]*command)
+	c.register(&command{name: "help", args: "[command]",
+		help: "show help for console commands",
+		fn:   (*Console).cmdHelp})
+	c.register(&command{name: "clear",
+		help: "clear the screen",
+		fn:   (*Console).cmdClear})
+	c.register(&command{name: "reset",
+		help: "discard the session data and start a new session",
+		fn:   (*Console).cmdReset})
+	c.register(&command{name: "history", args: "[clear]",
+		help:   "show or clear the command history",
+		values: []string{"clear"},
+		fn:     (*Console).cmdHistory})
+	c.register(&command{name: "theme", args: "[name]",
+		help:   "show or change the color theme",
+		values: terminal.ThemeNames(),
+		fn:     (*Console).cmdTheme})
+	c.register(&command{name: "set", args: "[name [value]]",
+		help:   "show or change console settings",
+		values: settingNames(),
+		fn:     (*Console).cmdSet})
+	c.register(&command{name: "expand", args: "[n]",
+		help: "show a collapsed JSON reply in full",
+		fn:   (*Console).cmdExpand})
+	c.register(&command{name: "export",
+		help: "download a transcript of the session",
+		fn:   (*Console).cmdExport})
+	c.register(&command{name: "share",
+		help: "show a link that lets others watch this session",
+		fn:   (*Console).cmdShare})
+	c.register(&command{name: "load", args: "[dataset]",
+		help:   "load a sample dataset into the session",
+		values: []string{"cities", "fleet", "regions"},
+		remote: true})
+	c.register(&command{name: "sim", args: "start [random|routes] [count [rate]] | stop | status",
+		help:   "control the moving object simulator",
+		values: []string{"start", "stop", "status"},
+		remote: true})
+}
+
+func (c *Console) register(cmd *command) {
+	c.commands[cmd.name] = cmd
+}
+
+func (c *Console) commandNames() []string {
+	var names []string
+	for name := range c.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// execCommand runs a console command line. Remote commands are passed to
+// send and the reply from the server will show the next prompt.
+func (c *Console) execCommand(line string, send func(string)) {
+	args := strings.Fields(strings.TrimPrefix(line, cmdPrefix))
+	if len(args) == 0 {
+		args = []string{"help"}
+	}
+	cmd := c.commands[strings.ToLower(args[0])]
+	switch {
+	case cmd == nil:
+		c.terminal.WriteString("\x1b[31m(error) unknown command '" + args[0] + "', type :help for a list of commands\x1b[0m\n")
+	case cmd.remote:
+		send(line)
+		return
+	default:
+		cmd.fn(c, args[1:])
+	}
+	if c.clid {
+		c.terminal.Prompt(c.prompt)
+	}
+}
+
+func (c *Console) cmdHelp(args []string) {
+	if len(args) > 0 {
+		cmd := c.commands[strings.ToLower(strings.TrimPrefix(args[0], cmdPrefix))]
+		if cmd == nil {
+			c.terminal.WriteString("\x1b[31m(error) unknown command '" + args[0] + "'\x1b[0m\n")
+			return
+		}
+		c.terminal.WriteString("\x1b[1m" + cmdPrefix + cmd.name + "\x1b[0m " + cmd.args + "\n  " + cmd.help + "\n")
+		return
+	}
+	msg := "Console commands:\n"
+	for _, name := range c.commandNames() {
+		cmd := c.commands[name]
+		msg += "  \x1b[1m" + cmdPrefix + pad(cmd.name, 8) + "\x1b[0m " + cmd.help + "\n"
+	}
+	msg += "Everything else is sent to Tile38. Type \x1b[1mHELP\x1b[0m for a list of Tile38 commands.\n"
+	c.terminal.WriteString(msg)
+}
+
+func (c *Console) cmdClear(args []string) {
+	c.terminal.Clear()
+}
+
+func (c *Console) cmdReset(args []string) {
+	c.resetSession()
+}
+
+func (c *Console) cmdShare(args []string) {
+	if c.shareID == "" {
+		c.terminal.WriteString("\x1b[31m(error) the session is not ready\x1b[0m\n")
+		return
+	}
+	c.terminal.WriteString("Anyone with this link can watch the session:\n  " + c.shareURL() + "\n")
+}
+
+func (c *Console) cmdHistory(args []string) {
+	if len(args) > 0 && strings.ToLower(args[0]) == "clear" {
+		c.history = nil
+		c.historyIdx = 0
+		js.Global.Get("localStorage").Call("removeItem", c.service+":history")
+		return
+	}
+	var msg string
+	for i, line := range c.history {
+		msg += pad(strconv.Itoa(i+1), 5) + line + "\n"
+	}
+	c.terminal.WriteString(msg)
+}
+
+func (c *Console) cmdTheme(args []string) {
+	if len(args) == 0 {
+		c.terminal.WriteString("theme: " + c.setting("theme") + " (" + strings.Join(terminal.ThemeNames(), ", ") + ")\n")
+		return
+	}
+	c.changeSetting("theme", args[0])
+}
+
+func (c *Console) cmdSet(args []string) {
+	switch len(args) {
+	case 0:
+		var msg string
+		for _, name := range settingNames() {
+			msg += pad(name, 10) + c.setting(name) + "\n"
+		}
+		c.terminal.WriteString(msg)
+	case 1:
+		if settingDefaults[args[0]] == "" {
+			c.terminal.WriteString("\x1b[31m(error) unknown setting '" + args[0] + "'\x1b[0m\n")
+			return
+		}
+		c.terminal.WriteString(args[0] + " = " + c.setting(args[0]) + "\n")
+	default:
+		c.changeSetting(args[0], strings.Join(args[1:], " "))
+	}
+}
+
+func (c *Console) cmdExpand(args []string) {
+	n := c.lastReply
+	if len(args) > 0 {
+		n, _ = strconv.Atoi(args[0])
+	}
+	c.expandReply(n)
+}
+
+func (c *Console) cmdExport(args []string) {
+	blob := js.Global.Get("Blob").New([]interface{}{c.terminal.Text()},
+		map[string]interface{}{"type": "text/plain"})
+	url := js.Global.Get("URL").Call("createObjectURL", blob)
+	a := js.Global.Get("document").Call("createElement", "a")
+	a.Set("href", url)
+	a.Set("download", c.service+"-transcript.txt")
+	js.Global.Get("document").Get("body").Call("appendChild", a)
+	a.Call("click")
+	js.Global.Get("document").Get("body").Call("removeChild", a)
+	js.Global.Get("URL").Call("revokeObjectURL", url)
+}
+
+// completeCommand completes a console command line and returns the
+// possible candidates.
+func (c *Console) completeCommand(line string) []string {
+	fields := strings.Fields(strings.TrimPrefix(line, cmdPrefix))
+	if len(fields) == 0 || (len(fields) == 1 && !strings.HasSuffix(line, " ")) {
+		var prefix string
+		if len(fields) == 1 {
+			prefix = strings.ToLower(fields[0])
+		}
+		var matches []string
+		for _, name := range c.commandNames() {
+			if strings.HasPrefix(name, prefix) {
+				matches = append(matches, cmdPrefix+name+" ")
+			}
+		}
+		return matches
+	}
+	cmd := c.commands[strings.ToLower(fields[0])]
+	if cmd == nil || len(fields) > 2 || (len(fields) == 2 && strings.HasSuffix(line, " ")) {
+		return nil
+	}
+	var prefix string
+	if len(fields) == 2 {
+		prefix = fields[1]
+	}
+	var matches []string
+	for _, value := range cmd.values {
+		if strings.HasPrefix(value, prefix) {
+			matches = append(matches, cmdPrefix+cmd.name+" "+value)
+		}
+	}
+	return matches
+}
+
+func pad(s string, n int) string {
+	for len(s) < n {
+		s += " "
+	}
+	return s
+}
@@ -0,0 +1,230 @@
+package console
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// cmdSpec describes the arguments of a Tile38 command for completion. Each
+// arg is a positional slot, which is "key" for a collection key, "id" for an
+// object id in that collection, "" for a free value, or a list of keywords
+// separated by '|'. Once the positional slots are used up, opts are offered
+// at every position.
+type cmdSpec struct {
+	args []string
+	opts []string
+}
+
+// Keywords shared by the search commands.
+var (
+	searchOpts = []string{"CURSOR", "LIMIT", "MATCH", "WHERE", "WHEREIN", "NOFIELDS",
+		"SPARSE", "DISTANCE", "FENCE", "DETECT", "COMMANDS", "COUNT", "IDS",
+		"OBJECTS", "POINTS", "BOUNDS", "HASHES"}
+	areaTypes = []string{"GET", "BOUNDS", "OBJECT", "TILE", "QUADKEY", "HASH",
+		"CIRCLE", "SECTOR"}
+	objectTypes = "OBJECT|POINT|BOUNDS|HASH|STRING"
+)
+
+var grammar = map[string]cmdSpec{
+	"SET":        {args: []string{"key", "id"}, opts: append([]string{"FIELD", "EX", "NX", "XX"}, strings.Split(objectTypes, "|")...)},
+	"GET":        {args: []string{"key", "id"}, opts: []string{"WITHFIELDS", "OBJECT", "POINT", "BOUNDS", "HASH"}},
+	"DEL":        {args: []string{"key", "id"}},
+	"DROP":       {args: []string{"key"}},
+	"PDEL":       {args: []string{"key"}},
+	"FSET":       {args: []string{"key", "id"}, opts: []string{"XX"}},
+	"FGET":       {args: []string{"key", "id"}},
+	"EXISTS":     {args: []string{"key", "id"}},
+	"FEXISTS":    {args: []string{"key", "id"}},
+	"EXPIRE":     {args: []string{"key", "id"}},
+	"PERSIST":    {args: []string{"key", "id"}},
+	"TTL":        {args: []string{"key", "id"}},
+	"JGET":       {args: []string{"key", "id"}, opts: []string{"RAW"}},
+	"JSET":       {args: []string{"key", "id"}, opts: []string{"RAW", "STR"}},
+	"JDEL":       {args: []string{"key", "id"}},
+	"BOUNDS":     {args: []string{"key"}},
+	"STATS":      {args: []string{"key"}},
+	"RENAME":     {args: []string{"key"}},
+	"RENAMENX":   {args: []string{"key"}},
+	"KEYS":       {},
+	"SCAN":       {args: []string{"key"}, opts: append([]string{"ASC", "DESC"}, searchOpts...)},
+	"SEARCH":     {args: []string{"key"}, opts: append([]string{"ASC", "DESC"}, searchOpts...)},
+	"NEARBY":     {args: []string{"key"}, opts: append([]string{"POINT", "ROAM"}, searchOpts...)},
+	"WITHIN":     {args: []string{"key"}, opts: append(append([]string{}, areaTypes...), searchOpts...)},
+	"INTERSECTS": {args: []string{"key"}, opts: append(append([]string{}, areaTypes...), searchOpts...)},
+	"SETHOOK":    {args: []string{"", "", "NEARBY|WITHIN|INTERSECTS", "key"}, opts: append([]string{"FENCE", "POINT"}, areaTypes...)},
+	"SETCHAN":    {args: []string{"", "NEARBY|WITHIN|INTERSECTS", "key"}, opts: append([]string{"FENCE", "POINT"}, areaTypes...)},
+	"DELHOOK":    {},
+	"PDELHOOK":   {},
+	"HOOKS":      {},
+	"DELCHAN":    {},
+	"PDELCHAN":   {},
+	"CHANS":      {},
+	"SUBSCRIBE":  {},
+	"PSUBSCRIBE": {},
+	"CONFIG":     {args: []string{"GET|SET|REWRITE"}},
+	"OUTPUT":     {args: []string{"JSON|RESP"}},
+	"READONLY":   {args: []string{"YES|NO"}},
+	"FLUSHDB":    {},
+	"GC":         {},
+	"AOF":        {},
+	"AOFMD5":     {},
+	"AOFSHRINK":  {},
+	"SERVER":     {},
+	"PING":       {},
+	"QUIT":       {},
+	"HELP":       {},
+	"TIMEOUT":    {},
+	"ECHO":       {},
+	"INFO":       {},
+	"ROLE":       {},
+	"HEALTHZ":    {},
+	"AUTH":       {},
+	"CLIENT":     {args: []string{"LIST|KILL|GETNAME|SETNAME"}},
+	"TEST":       {},
+	"EVAL":       {},
+	"EVALRO":     {},
+	"EVALNA":     {},
+	"EVALSHA":    {},
+	"SCRIPT":     {args: []string{"LOAD|EXISTS|FLUSH"}},
+}
+
+// completeTile38 completes a Tile38 command line and returns the possible
+// candidates as whole lines.
+func (c *Console) completeTile38(line string) []string {
+	fields := strings.Fields(line)
+	var word string
+	if len(fields) > 0 && !strings.HasSuffix(line, " ") {
+		word = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+	head := line[:len(line)-len(word)]
+	var values []string
+	if len(fields) == 0 {
+		for name := range grammar {
+			values = append(values, name)
+		}
+		sort.Strings(values)
+	} else {
+		spec, ok := grammar[strings.ToUpper(fields[0])]
+		if !ok {
+			return nil
+		}
+		if pos := len(fields) - 1; pos < len(spec.args) {
+			switch slot := spec.args[pos]; slot {
+			case "key":
+				values = c.keyNames()
+			case "id":
+				// the key is in the slot before the id
+				values = c.keys[fields[pos]]
+			case "":
+			default:
+				values = strings.Split(slot, "|")
+			}
+		} else {
+			values = spec.opts
+		}
+	}
+	var matches []string
+	for _, value := range values {
+		if !strings.HasPrefix(strings.ToUpper(value), strings.ToUpper(word)) {
+			continue
+		}
+		if value == strings.ToUpper(value) && word != "" && word == strings.ToLower(word) {
+			// follow the case the user is typing in
+			value = strings.ToLower(value)
+		}
+		matches = append(matches, head+value+" ")
+	}
+	return matches
+}
+
+func (c *Console) keyNames() []string {
+	var names []string
+	for key := range c.keys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// refreshKeys fetches the collection keys and ids of the session in the
+// background.
+func (c *Console) refreshKeys() {
+	if c.id == "" || c.keysLoading {
+		return
+	}
+	c.keysLoading = true
+	c.keysStale = false
+	req := js.Global.Get("XMLHttpRequest").New()
+	req.Call("open", "GET", "/"+c.service+"-keys/"+c.id)
+	req.Call("addEventListener", "loadend", func() {
+		c.keysLoading = false
+		if req.Get("status").Int() != 200 {
+			return
+		}
+		obj := js.Global.Get("JSON").Call("parse", req.Get("responseText"))
+		keys := make(map[string][]string)
+		names := js.Global.Get("Object").Call("keys", obj)
+		for i := 0; i < names.Length(); i++ {
+			key := names.Index(i).String()
+			ids := obj.Get(key)
+			for j := 0; j < ids.Length(); j++ {
+				keys[key] = append(keys[key], ids.Index(j).String())
+			}
+		}
+		c.keys = keys
+	})
+	req.Call("send")
+}
+
+// complete is called when the user presses Tab. A single candidate is
+// inserted, otherwise the common prefix is. Pressing Tab again without a
+// change lists the candidates.
+func (c *Console) complete() {
+	if c.keysStale {
+		// used from the next Tab on
+		c.refreshKeys()
+	}
+	input := c.terminal.GetInput()
+	var matches []string
+	if strings.HasPrefix(input, cmdPrefix) {
+		matches = c.completeCommand(input)
+	} else {
+		matches = c.completeTile38(input)
+	}
+	switch len(matches) {
+	case 0:
+		return
+	case 1:
+		c.terminal.SetInput(matches[0])
+		return
+	}
+	prefix := commonPrefix(matches)
+	if len(prefix) > len(input) {
+		c.terminal.SetInput(prefix)
+		c.tabInput = prefix
+		return
+	}
+	if c.tabInput != input {
+		c.tabInput = input
+		return
+	}
+	var msg string
+	for _, match := range matches {
+		word := strings.TrimSpace(match)
+		msg += word[strings.LastIndex(word, " ")+1:] + "  "
+	}
+	c.terminal.WriteString(c.prompt + input + "\n" + msg + "\n")
+}
+
+func commonPrefix(strs []string) string {
+	prefix := strs[0]
+	for _, s := range strs[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
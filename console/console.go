@@ -1,6 +1,7 @@
 package console
 
 import (
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,6 +21,17 @@ type Console struct {
 	lastInput    string
 	service      string
 	prompt       string
+	commands     map[string]*command
+	server       *js.Object
+	cli          *js.Object
+	shareID      string
+	search       *histSearch
+	keys         map[string][]string // collection keys and ids for completion
+	keysStale    bool                // fetch the keys on the next Tab
+	keysLoading  bool
+	tabInput     string
+	replies      map[int]*jsonValue // collapsed replies by number
+	lastReply    int
 }
 
 func New(parent *js.Object, service string) (*Console, error) {
@@ -32,8 +44,25 @@ func New(parent *js.Object, service string) (*Console, error) {
 		service:  service,
 		prompt:   strings.Replace(prompt, "%s", service, -1),
 	}
+	c.registerCommands()
+	c.applySettings()
+	c.terminal.ZoomChanged = func(zoom int) {
+		c.changeSetting("zoom", strconv.Itoa(zoom))
+	}
+	c.terminal.Tab = c.complete
+	c.terminal.Highlight = c.highlight
+	c.terminal.Incomplete = incomplete
+	c.terminal.Click = c.clickOutput
+	c.terminal.LinkDetectors = append(c.terminal.LinkDetectors, c.detectObjects)
+	c.terminal.BindView("Alt-E", func() { c.expandReply(c.lastReply) })
+	c.terminal.ContinuePrompt = strings.Replace(prompt, "%s", strings.Repeat(".", len(service)), -1)
+	c.terminal.BindView("Ctrl-R", c.startSearch)
 	c.showMessage()
-	c.loadServer()
+	if sid := sharedSession(); sid != "" {
+		c.loadWatch(sid)
+	} else {
+		c.loadServer()
+	}
 	c.loadHistory()
 	return c, nil
 }
@@ -59,11 +88,35 @@ func (c *Console) loadServer() {
 		scheme = "wss"
 	}
 	ws := js.Global.Get("WebSocket").New(scheme + "://" + host + "/" + c.service + "-server/" + id)
+	c.server = ws
 	ws.Call("addEventListener", "close", func(ev *js.Object) {
 		println("server closed")
 		c.terminal.ClearInput()
-		c.terminal.WriteString("\x1b[31mServer closed: please refresh the page to start a new session.\x1b[0m\n")
+		c.terminal.WriteString("\x1b[31mServer closed: type :reset to start a new session.\x1b[0m\n")
 		c.serverOpened = false
+		c.clid = false
+		if c.cli != nil {
+			cli := c.cli
+			c.cli = nil
+			cli.Call("close")
+		}
+		// only console commands work until the session is reset
+		closed := func(string) {
+			c.terminal.WriteString("\x1b[31m(error) server closed, type :reset to start a new session\x1b[0m\n")
+		}
+		c.terminal.Up = nil
+		c.terminal.Down = nil
+		c.terminal.Input = func(s string) {
+			if strings.HasPrefix(s, cmdPrefix) {
+				c.execCommand(s, closed)
+			} else {
+				closed(s)
+			}
+			if c.server == ws {
+				c.terminal.Prompt(c.prompt)
+			}
+		}
+		c.terminal.Prompt(c.prompt)
 	})
 	ws.Call("addEventListener", "open", func() {
 		println("server opened")
@@ -79,6 +132,8 @@ func (c *Console) loadServer() {
 			c.id = str[4:]
 			js.Global.Get("localStorage").Call("setItem", c.service+":session:id", c.id)
 			println(c.id)
+		case strings.HasPrefix(str, "share: "):
+			c.shareID = str[7:]
 		case strings.HasPrefix(str, "stderr: ") || strings.HasPrefix(str, "stdout: "):
 			if !c.clid {
 				s := str[8:]
@@ -92,6 +147,50 @@ func (c *Console) loadServer() {
 	})
 }
 
+// sharedSession returns the share id from a '#s=<id>' page url.
+func sharedSession() string {
+	hash := js.Global.Get("location").Get("hash").String()
+	if strings.HasPrefix(hash, "#s=") {
+		return hash[3:]
+	}
+	return ""
+}
+
+// shareURL returns a link that lets others watch the session.
+func (c *Console) shareURL() string {
+	loc := js.Global.Get("location")
+	return loc.Get("origin").String() + loc.Get("pathname").String() + "#s=" + c.shareID
+}
+
+// loadWatch connects to a shared session as a read-only spectator.
+func (c *Console) loadWatch(sid string) {
+	host := js.Global.Get("window").Get("location").Get("host").String()
+	scheme := "ws"
+	if js.Global.Get("window").Get("location").Get("protocol").String() == "https:" {
+		scheme = "wss"
+	}
+	ws := js.Global.Get("WebSocket").New(scheme + "://" + host + "/" + c.service + "-watch/" + sid)
+	ws.Call("addEventListener", "open", func() {
+		println("watch opened")
+		c.terminal.WriteString("\x1b[32mWatching a shared session (read-only).\x1b[0m\n")
+	})
+	ws.Call("addEventListener", "close", func(ev *js.Object) {
+		println("watch closed")
+		c.terminal.WriteString("\x1b[31mThe shared session has ended.\x1b[0m\n")
+	})
+	ws.Call("addEventListener", "message", func(ev *js.Object) {
+		str := ev.Get("data").String()
+		switch {
+		case strings.HasPrefix(str, "err: "):
+			c.terminal.WriteString("\x1b[31m" + str[5:] + "\x1b[0m\n")
+		case strings.HasPrefix(str, "input: "):
+			c.terminal.WriteString(c.prompt + str[7:] + "\n")
+		case strings.HasPrefix(str, "stderr: ") || strings.HasPrefix(str, "stdout: "):
+			c.terminal.WriteString(c.formatOutput(str[8:]))
+		}
+	})
+}
+
 func (c *Console) loadCLI() {
 	noMorePrompts := false
 	lastLive := false
@@ -101,9 +200,14 @@ func (c *Console) loadCLI() {
 		scheme = "wss"
 	}
 	ws := js.Global.Get("WebSocket").New(scheme + "://" + host + "/" + c.service + "-cli/" + c.id)
+	c.cli = ws
 
 	ws.Call("addEventListener", "close", func(ev *js.Object) {
 		println("cli closed")
+		if c.cli != ws {
+			// replaced by a session reset
+			return
+		}
 		c.terminal.Input = nil
 		c.terminal.Up = nil
 		c.terminal.Down = nil
@@ -111,7 +215,7 @@ func (c *Console) loadCLI() {
 			c.terminal.WriteString("\x1b[31mCLI closed: trying again.\x1b[0m\n")
 			go func() {
 				time.Sleep(time.Second)
-				if c.serverOpened {
+				if c.serverOpened && c.cli == ws {
 					c.loadCLI()
 				}
 			}()
@@ -121,13 +225,24 @@ func (c *Console) loadCLI() {
 		println("cli opened")
 		c.terminal.WriteString("\n")
 		c.terminal.Prompt(c.prompt)
+		c.refreshKeys()
 		c.terminal.Input = func(s string) {
+			if strings.HasPrefix(s, cmdPrefix) {
+				c.storeHistory(s)
+				c.execCommand(s, func(line string) {
+					lastLive = false
+					ws.Call("send", line)
+				})
+				return
+			}
 			if strings.HasPrefix(strings.ToLower(s), "aof ") {
 				lastLive = true
 			} else {
 				lastLive = false
 			}
-			ws.Call("send", s)
+			for _, line := range splitCommands(s, c.terminal.PastedLines()) {
+				ws.Call("send", line)
+			}
 			c.storeHistory(s)
 		}
 		c.terminal.Up = func() {
@@ -169,14 +284,14 @@ func (c *Console) loadCLI() {
 		switch {
 		case strings.HasPrefix(str, "stderr: "):
 			s := str[8:]
-			c.terminal.WriteString(s)
+			c.terminal.WriteString(c.formatOutput(s))
 			if strings.TrimSpace(s) == `{"ok":true,"live":true}` {
 				c.terminal.WriteString("\x1b[32mYou are in live mode. No more input allowed.\x1b[0m\n")
 				noMorePrompts = true
 			}
 		case strings.HasPrefix(str, "stdout: "):
 			s := str[8:]
-			c.terminal.WriteString(s)
+			c.terminal.WriteString(c.formatOutput(s))
 			if (s == "+OK\r\n" || s == "+OK\n") && lastLive {
 				c.terminal.WriteString("\x1b[32mYou are in live mode. No more input allowed.\x1b[0m\n")
 				noMorePrompts = true
@@ -184,12 +299,65 @@ func (c *Console) loadCLI() {
 		default:
 			return
 		}
+		// the reply may follow a write, so the keys are fetched again on
+		// the next Tab
+		c.keysStale = true
 		if !noMorePrompts {
 			c.terminal.Prompt(c.prompt)
 		}
 	})
 }
 
+// resetSession asks the server to discard the session data and start a new
+// session, or connects again when the server has closed, then reconnects the
+// CLI once the new server is ready.
+func (c *Console) resetSession() {
+	c.clid = false
+	if c.cli != nil {
+		cli := c.cli
+		c.cli = nil
+		cli.Call("close")
+	}
+	c.terminal.Input = nil
+	c.terminal.Up = nil
+	c.terminal.Down = nil
+	c.terminal.ClearInput()
+	c.terminal.Clear()
+	c.terminal.WriteString("\x1b[32mStarting a new session...\x1b[0m\n")
+	if !c.serverOpened {
+		// the old session is gone with the server, so connect for a
+		// new one
+		js.Global.Get("localStorage").Call("removeItem", c.service+":session:id")
+		c.id = ""
+		c.loadServer()
+		return
+	}
+	c.server.Call("send", "reset")
+}
+
+// splitCommands splits multi-line input into the commands to send, since
+// tile38-cli reads a command per line. A paste of several complete commands
+// is sent as separate commands, otherwise the lines are joined into one, as
+// with a command typed over several lines.
+func splitCommands(input string, pasted bool) []string {
+	lines := strings.Split(input, "\n")
+	if !pasted {
+		return []string{strings.Join(lines, " ")}
+	}
+	for _, line := range lines {
+		if incomplete(line) {
+			return []string{strings.Join(lines, " ")}
+		}
+	}
+	var cmds []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			cmds = append(cmds, line)
+		}
+	}
+	return cmds
+}
+
 var histdel = "\n_HISTDEL_\n"
 
 func (c *Console) loadHistory() {
@@ -0,0 +1,27 @@
+package console
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommands(t *testing.T) {
+	tests := []struct {
+		input  string
+		pasted bool
+		want   []string
+	}{
+		{"PING", false, []string{"PING"}},
+		{"PING", true, []string{"PING"}},
+		{"SET fleet t1\nPOINT 33 -115", false, []string{"SET fleet t1 POINT 33 -115"}},
+		{"SET fleet t1 POINT 33 -115\nGET fleet t1", true, []string{"SET fleet t1 POINT 33 -115", "GET fleet t1"}},
+		{"SET a b\n\n  \nGET a b\n", true, []string{"SET a b", "GET a b"}},
+		{"SET a b STRING \"x\ny\"", true, []string{"SET a b STRING \"x y\""}},
+		{"SET a b STRING 'x\ny'", false, []string{"SET a b STRING 'x y'"}},
+	}
+	for _, tt := range tests {
+		if got := splitCommands(tt.input, tt.pasted); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommands(%q, %v) = %q, want %q", tt.input, tt.pasted, got, tt.want)
+		}
+	}
+}
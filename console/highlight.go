@@ -0,0 +1,175 @@
+package console
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SGR sequences used to highlight the input line.
+const (
+	hlCommand = "\x1b[1;36m"
+	hlKeyword = "\x1b[35m"
+	hlNumber  = "\x1b[33m"
+	hlString  = "\x1b[32m"
+	hlJSON    = "\x1b[34m"
+	hlError   = "\x1b[31m"
+	hlReset   = "\x1b[0m"
+)
+
+// highlight colors the input line as it's typed. Unknown commands and
+// unterminated strings or JSON objects are shown in red.
+func (c *Console) highlight(input string) string {
+	var out []byte
+	var spec *cmdSpec
+	var known bool // the command name is valid
+	first := true
+	for i := 0; i < len(input); {
+		if input[i] == ' ' || input[i] == '\n' {
+			out = append(out, input[i])
+			i++
+			continue
+		}
+		end, ok := tokenEnd(input, i)
+		tok := input[i:end]
+		var style string
+		switch {
+		case !ok:
+			style = hlError
+		case first:
+			if strings.HasPrefix(tok, cmdPrefix) {
+				_, known = c.commands[strings.ToLower(tok[len(cmdPrefix):])]
+			} else if s, ok := grammar[strings.ToUpper(tok)]; ok {
+				spec, known = &s, true
+			}
+			style = hlCommand
+			if !known {
+				style = hlError
+				if end == len(input) && c.isCommandPrefix(tok) {
+					// still typing the command
+					style = ""
+				}
+			}
+		case tok[0] == '"' || tok[0] == '\'':
+			style = hlString
+		case tok[0] == '{':
+			style = hlJSON
+		case isNumber(tok):
+			style = hlNumber
+		case spec != nil && spec.isKeyword(tok):
+			style = hlKeyword
+		}
+		first = false
+		if style != "" {
+			out = append(out, style...)
+			out = append(out, tok...)
+			out = append(out, hlReset...)
+		} else {
+			out = append(out, tok...)
+		}
+		i = end
+	}
+	return string(out)
+}
+
+// tokenEnd returns the end of the token starting at i. Quoted strings and
+// JSON objects may contain spaces. It returns false when a string or object
+// isn't closed.
+func tokenEnd(s string, i int) (int, bool) {
+	switch s[i] {
+	case '"', '\'':
+		q := s[i]
+		for j := i + 1; j < len(s); j++ {
+			if s[j] == '\\' {
+				j++
+			} else if s[j] == q {
+				return j + 1, true
+			}
+		}
+		return len(s), false
+	case '{':
+		depth := 0
+		var q byte
+		for j := i; j < len(s); j++ {
+			switch {
+			case q != 0:
+				if s[j] == '\\' {
+					j++
+				} else if s[j] == q {
+					q = 0
+				}
+			case s[j] == '"':
+				q = '"'
+			case s[j] == '{' || s[j] == '[':
+				depth++
+			case s[j] == '}' || s[j] == ']':
+				depth--
+				if depth == 0 {
+					return j + 1, true
+				}
+			}
+		}
+		return len(s), false
+	}
+	j := strings.IndexAny(s[i:], " \n")
+	if j == -1 {
+		return len(s), true
+	}
+	return i + j, true
+}
+
+// incomplete reports whether the input has an unterminated string or JSON
+// object, in which case Enter continues the input on the next line.
+func incomplete(input string) bool {
+	for i := 0; i < len(input); {
+		if input[i] == ' ' || input[i] == '\n' {
+			i++
+			continue
+		}
+		end, ok := tokenEnd(input, i)
+		if !ok {
+			return true
+		}
+		i = end
+	}
+	return false
+}
+
+// isCommandPrefix reports whether tok is the start of a command name.
+func (c *Console) isCommandPrefix(tok string) bool {
+	if strings.HasPrefix(tok, cmdPrefix) {
+		return len(c.completeCommand(tok)) > 0
+	}
+	tok = strings.ToUpper(tok)
+	for name := range grammar {
+		if strings.HasPrefix(name, tok) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// isKeyword reports whether tok is one of the keywords of the command.
+func (spec *cmdSpec) isKeyword(tok string) bool {
+	tok = strings.ToUpper(tok)
+	for _, opt := range spec.opts {
+		if opt == tok {
+			return true
+		}
+	}
+	for _, slot := range spec.args {
+		if slot == "key" || slot == "id" || slot == "" {
+			continue
+		}
+		for _, kw := range strings.Split(slot, "|") {
+			if kw == tok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,309 @@
+package console
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// SGR sequences used for pretty-printed JSON.
+const (
+	jsonKey     = "\x1b[36m"
+	jsonString  = "\x1b[32m"
+	jsonNumber  = "\x1b[33m"
+	jsonLiteral = "\x1b[35m"
+	jsonHint    = "\x1b[2m"
+)
+
+const (
+	jsonIndent     = "  "
+	jsonInlineMax  = 60 // values up to this length stay on one line
+	jsonCollapseAt = 10 // arrays with more items are collapsed
+	maxReplies     = 50 // collapsed replies kept for expanding
+)
+
+// jsonValue is a parsed JSON value. Object keys keep the order of the reply.
+type jsonValue struct {
+	kind  byte   // '{', '[', '"', '0' for numbers or 'l' for literals
+	raw   string // strings, numbers and literals as they appear in the reply
+	keys  []string
+	items []*jsonValue
+}
+
+var errJSON = errors.New("invalid json")
+
+// parseJSON parses a complete JSON document.
+func parseJSON(s string) (*jsonValue, error) {
+	p := &jsonParser{s: s}
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	p.space()
+	if p.i != len(p.s) {
+		return nil, errJSON
+	}
+	return v, nil
+}
+
+type jsonParser struct {
+	s string
+	i int
+}
+
+func (p *jsonParser) space() {
+	for p.i < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.i]) != -1 {
+		p.i++
+	}
+}
+
+func (p *jsonParser) value() (*jsonValue, error) {
+	p.space()
+	if p.i == len(p.s) {
+		return nil, errJSON
+	}
+	switch ch := p.s[p.i]; {
+	case ch == '{' || ch == '[':
+		return p.container(ch)
+	case ch == '"':
+		raw, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		return &jsonValue{kind: '"', raw: raw}, nil
+	case ch == '-' || (ch >= '0' && ch <= '9'):
+		start := p.i
+		for p.i < len(p.s) && strings.IndexByte("+-.eE0123456789", p.s[p.i]) != -1 {
+			p.i++
+		}
+		raw := p.s[start:p.i]
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, errJSON
+		}
+		return &jsonValue{kind: '0', raw: raw}, nil
+	default:
+		for _, lit := range []string{"true", "false", "null"} {
+			if strings.HasPrefix(p.s[p.i:], lit) {
+				p.i += len(lit)
+				return &jsonValue{kind: 'l', raw: lit}, nil
+			}
+		}
+		return nil, errJSON
+	}
+}
+
+// str returns the quoted string at the current position, quotes included.
+func (p *jsonParser) str() (string, error) {
+	start := p.i
+	for p.i++; p.i < len(p.s); p.i++ {
+		switch p.s[p.i] {
+		case '\\':
+			p.i++
+		case '"':
+			p.i++
+			return p.s[start:p.i], nil
+		}
+	}
+	return "", errJSON
+}
+
+func (p *jsonParser) container(open byte) (*jsonValue, error) {
+	v := &jsonValue{kind: open}
+	close := byte(']')
+	if open == '{' {
+		close = '}'
+	}
+	p.i++
+	p.space()
+	if p.i < len(p.s) && p.s[p.i] == close {
+		p.i++
+		return v, nil
+	}
+	for {
+		if open == '{' {
+			p.space()
+			if p.i == len(p.s) || p.s[p.i] != '"' {
+				return nil, errJSON
+			}
+			key, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			p.space()
+			if p.i == len(p.s) || p.s[p.i] != ':' {
+				return nil, errJSON
+			}
+			p.i++
+			v.keys = append(v.keys, key)
+		}
+		item, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		v.items = append(v.items, item)
+		p.space()
+		if p.i == len(p.s) {
+			return nil, errJSON
+		}
+		switch p.s[p.i] {
+		case ',':
+			p.i++
+		case close:
+			p.i++
+			return v, nil
+		default:
+			return nil, errJSON
+		}
+	}
+}
+
+// compact returns the value on one line without colors.
+func (v *jsonValue) compact() string {
+	if v.kind != '{' && v.kind != '[' {
+		return v.raw
+	}
+	var parts []string
+	for i, item := range v.items {
+		if v.kind == '{' {
+			parts = append(parts, v.keys[i]+":"+item.compact())
+		} else {
+			parts = append(parts, item.compact())
+		}
+	}
+	if v.kind == '{' {
+		return "{" + strings.Join(parts, ",") + "}"
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// jsonPrinter writes pretty-printed JSON. When collapse is set, long
+// arrays are cut short and hint is called for the line that replaces the
+// remaining items.
+type jsonPrinter struct {
+	buf       []byte
+	collapse  bool
+	collapsed bool // some items were left out
+	hint      func(more int) string
+}
+
+func (pr *jsonPrinter) print(v *jsonValue, indent string) {
+	switch v.kind {
+	case '"':
+		pr.buf = append(pr.buf, jsonString+v.raw+hlReset...)
+		return
+	case '0':
+		pr.buf = append(pr.buf, jsonNumber+v.raw+hlReset...)
+		return
+	case 'l':
+		pr.buf = append(pr.buf, jsonLiteral+v.raw+hlReset...)
+		return
+	}
+	close := "]"
+	if v.kind == '{' {
+		close = "}"
+	}
+	if len(v.items) == 0 || len(v.compact()) <= jsonInlineMax {
+		pr.inline(v)
+		return
+	}
+	items := v.items
+	if pr.collapse && v.kind == '[' && len(items) > jsonCollapseAt {
+		items = items[:jsonCollapseAt]
+	}
+	pr.buf = append(pr.buf, v.kind, '\n')
+	inner := indent + jsonIndent
+	for i, item := range items {
+		pr.buf = append(pr.buf, inner...)
+		if v.kind == '{' {
+			pr.buf = append(pr.buf, jsonKey+v.keys[i]+hlReset+": "...)
+		}
+		pr.print(item, inner)
+		if i < len(v.items)-1 {
+			pr.buf = append(pr.buf, ',')
+		}
+		pr.buf = append(pr.buf, '\n')
+	}
+	if more := len(v.items) - len(items); more > 0 {
+		pr.collapsed = true
+		pr.buf = append(pr.buf, inner+jsonHint+pr.hint(more)+hlReset+"\n"...)
+	}
+	pr.buf = append(pr.buf, indent+close...)
+}
+
+// inline writes a short value on one line with colors.
+func (pr *jsonPrinter) inline(v *jsonValue) {
+	if v.kind != '{' && v.kind != '[' {
+		pr.print(v, "")
+		return
+	}
+	pr.buf = append(pr.buf, v.kind)
+	for i, item := range v.items {
+		if i > 0 {
+			pr.buf = append(pr.buf, ',')
+		}
+		if v.kind == '{' {
+			pr.buf = append(pr.buf, jsonKey+v.keys[i]+hlReset+":"...)
+		}
+		pr.inline(item)
+	}
+	if v.kind == '{' {
+		pr.buf = append(pr.buf, '}')
+	} else {
+		pr.buf = append(pr.buf, ']')
+	}
+}
+
+// formatOutput pretty-prints a line of output when it's a JSON reply and
+// the json setting is "pretty". Other output is returned as is.
+func (c *Console) formatOutput(s string) string {
+	if c.setting("json") != "pretty" {
+		return s
+	}
+	line := strings.TrimRight(s, "\r\n")
+	if !strings.HasPrefix(line, "{") && !strings.HasPrefix(line, "[") {
+		return s
+	}
+	v, err := parseJSON(line)
+	if err != nil {
+		return s
+	}
+	n := c.lastReply + 1
+	pr := &jsonPrinter{collapse: true}
+	pr.hint = func(more int) string {
+		return "… " + strconv.Itoa(more) + " more objects (click or :expand " + strconv.Itoa(n) + ")"
+	}
+	pr.print(v, "")
+	if pr.collapsed {
+		if c.replies == nil {
+			c.replies = make(map[int]*jsonValue)
+		}
+		c.replies[n] = v
+		delete(c.replies, n-maxReplies)
+		c.lastReply = n
+	}
+	return string(pr.buf) + "\n"
+}
+
+// expandReply prints collapsed reply n in full.
+func (c *Console) expandReply(n int) {
+	v := c.replies[n]
+	if v == nil {
+		c.terminal.WriteString("\x1b[31m(error) no collapsed reply " + strconv.Itoa(n) + "\x1b[0m\n")
+		return
+	}
+	pr := &jsonPrinter{}
+	pr.print(v, "")
+	c.terminal.WriteString(string(pr.buf) + "\n")
+}
+
+// clickOutput expands a collapsed reply when its hint is clicked.
+func (c *Console) clickOutput(line string, col int) {
+	i := strings.Index(line, ":expand ")
+	if i == -1 || !strings.Contains(line, " more objects ") {
+		return
+	}
+	n, err := strconv.Atoi(strings.TrimRight(line[i+len(":expand "):], ") "))
+	if err == nil {
+		c.expandReply(n)
+	}
+}
@@ -0,0 +1,94 @@
+package console
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // compact form, empty when invalid
+	}{
+		{`{}`, `{}`},
+		{`[]`, `[]`},
+		{`  "a b"  `, `"a b"`},
+		{`-1.5e3`, `-1.5e3`},
+		{`true`, `true`},
+		{`null`, `null`},
+		{`{"ok":true,"elapsed":"1.2µs"}`, `{"ok":true,"elapsed":"1.2µs"}`},
+		{`{ "b" : 1 , "a" : [ 1, 2 ] }`, `{"b":1,"a":[1,2]}`},
+		{`["say \"hi\"", "\\"]`, `["say \"hi\"","\\"]`},
+		{`{"a":{"b":{"c":[]}}}`, `{"a":{"b":{"c":[]}}}`},
+		{``, ``},
+		{`{`, ``},
+		{`[1,]`, ``},
+		{`[1 2]`, ``},
+		{`{"a"}`, ``},
+		{`{a:1}`, ``},
+		{`"open`, ``},
+		{`1.2.3`, ``},
+		{`nope`, ``},
+		{`{} {}`, ``},
+	}
+	for _, tt := range tests {
+		v, err := parseJSON(tt.in)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("parseJSON(%q) = %q, want error", tt.in, v.compact())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseJSON(%q): %v", tt.in, err)
+			continue
+		}
+		if got := v.compact(); got != tt.want {
+			t.Errorf("parseJSON(%q).compact() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+var sgrPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestJSONPrinter(t *testing.T) {
+	long := `"` + strings.Repeat("x", jsonInlineMax) + `"`
+	var items []string
+	for i := 0; i < jsonCollapseAt+3; i++ {
+		items = append(items, long)
+	}
+	tests := []struct {
+		in       string
+		collapse bool
+		want     string
+	}{
+		{`{"ok":true}`, false, `{"ok":true}`},
+		{`[1, 2, 3]`, false, `[1,2,3]`},
+		{`{"a":` + long + `,"b":[]}`, false, "{\n  \"a\": " + long + ",\n  \"b\": []\n}"},
+		{`{"a":{"b":` + long + `}}`, false, "{\n  \"a\": {\n    \"b\": " + long + "\n  }\n}"},
+		{`[` + strings.Join(items[:2], ",") + `]`, true, "[\n  " + long + ",\n  " + long + "\n]"},
+		{`[` + strings.Join(items, ",") + `]`, true,
+			"[\n" + strings.Repeat("  "+long+",\n", jsonCollapseAt) + "  3 more\n]"},
+		{`[` + strings.Join(items, ",") + `]`, false,
+			"[\n" + strings.Repeat("  "+long+",\n", len(items)-1) + "  " + long + "\n]"},
+	}
+	for _, tt := range tests {
+		v, err := parseJSON(tt.in)
+		if err != nil {
+			t.Fatalf("parseJSON(%q): %v", tt.in, err)
+		}
+		pr := &jsonPrinter{collapse: tt.collapse}
+		pr.hint = func(more int) string {
+			return strconv.Itoa(more) + " more"
+		}
+		pr.print(v, "")
+		if got := sgrPattern.ReplaceAllString(string(pr.buf), ""); got != tt.want {
+			t.Errorf("print(%q) =\n%s\nwant\n%s", tt.in, got, tt.want)
+		}
+		if want := strings.Contains(tt.want, " more"); pr.collapsed != want {
+			t.Errorf("print(%q): collapsed = %v, want %v", tt.in, pr.collapsed, want)
+		}
+	}
+}
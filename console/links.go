@@ -0,0 +1,94 @@
+package console
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tile38/try/terminal"
+)
+
+const maxReplyLines = 1000 // lines searched above a reply for its command
+
+var (
+	idField  = regexp.MustCompile(`"id":\s*("(?:[^"\\]|\\.)*")`)
+	keyField = regexp.MustCompile(`"key":\s*("(?:[^"\\]|\\.)*")`)
+)
+
+// detectObjects links the object ids in replies to a GET of the object. The
+// key is taken from the same line, as in hook notifications, or otherwise
+// from the command that was replied to.
+func (c *Console) detectObjects(line string, above func(n int) (string, bool)) []terminal.Link {
+	ids := idField.FindAllStringSubmatchIndex(line, -1)
+	if ids == nil {
+		return nil
+	}
+	var key string
+	if m := keyField.FindStringSubmatch(line); m != nil {
+		key = jsonArg(m[1])
+	} else {
+		key = c.replyKey(above)
+	}
+	if key == "" {
+		return nil
+	}
+	var links []terminal.Link
+	for _, loc := range ids {
+		input := "GET " + key + " " + jsonArg(line[loc[2]:loc[3]])
+		links = append(links, terminal.Link{Start: loc[2], End: loc[3], Open: func() {
+			c.terminal.SetInput(input)
+		}})
+	}
+	return links
+}
+
+// replyKey returns the collection key of the command that the output
+// below it replies to. The command is found by its echoed prompt.
+func (c *Console) replyKey(above func(n int) (string, bool)) string {
+	echo := c.service + "> "
+	for n := 1; n <= maxReplyLines; n++ {
+		line, ok := above(n)
+		if !ok {
+			break
+		}
+		if strings.HasPrefix(line, echo) {
+			return commandKey(line[len(echo):])
+		}
+	}
+	return ""
+}
+
+// commandKey returns the key argument of a command as it was typed, or an
+// empty string for commands without one.
+func commandKey(cmd string) string {
+	var toks []string
+	for i := 0; i < len(cmd); {
+		if cmd[i] == ' ' {
+			i++
+			continue
+		}
+		end, _ := tokenEnd(cmd, i)
+		toks = append(toks, cmd[i:end])
+		i = end
+	}
+	if len(toks) == 0 {
+		return ""
+	}
+	spec := grammar[strings.ToUpper(toks[0])]
+	for i, arg := range spec.args {
+		if arg == "key" && i+1 < len(toks) {
+			return toks[i+1]
+		}
+	}
+	return ""
+}
+
+// jsonArg returns a JSON string as a command argument, which is only
+// quoted when it needs to be.
+func jsonArg(quoted string) string {
+	s, err := strconv.Unquote(quoted)
+	if err != nil || s == "" || strings.ContainsAny(s, " \t\"'") {
+		return quoted
+	}
+	return s
+}
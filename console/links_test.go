@@ -0,0 +1,78 @@
+package console
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCommandKey(t *testing.T) {
+	tests := []struct {
+		cmd, key string
+	}{
+		{"GET fleet truck1", "fleet"},
+		{"get fleet truck1", "fleet"},
+		{"  SET  fleet truck1 POINT 33 -115", "fleet"},
+		{`SET "my fleet" truck1 POINT 33 -115`, `"my fleet"`},
+		{"NEARBY fleet POINT 33 -115 1000", "fleet"},
+		{"SET", ""},
+		{"KEYS *", ""},
+		{"PING", ""},
+		{"UNKNOWN fleet", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := commandKey(tt.cmd); got != tt.key {
+			t.Errorf("commandKey(%q) = %q, want %q", tt.cmd, got, tt.key)
+		}
+	}
+}
+
+func TestJSONArg(t *testing.T) {
+	tests := []struct {
+		quoted, arg string
+	}{
+		{`"truck1"`, "truck1"},
+		{`"truck 1"`, `"truck 1"`},
+		{`"say \"hi\""`, `"say \"hi\""`},
+		{`""`, `""`},
+		{`"café"`, "café"},
+		{`"bad\q"`, `"bad\q"`},
+	}
+	for _, tt := range tests {
+		if got := jsonArg(tt.quoted); got != tt.arg {
+			t.Errorf("jsonArg(%q) = %q, want %q", tt.quoted, got, tt.arg)
+		}
+	}
+}
+
+func TestDetectObjects(t *testing.T) {
+	c := &Console{service: "tile38"}
+	tests := []struct {
+		above []string // output above the line, nearest last
+		line  string
+		ids   []string // linked text
+	}{
+		{nil, `{"ok":true,"objects":[{"id":"truck1"},{"id":"truck2"}]}`, nil},
+		{[]string{"tile38> SCAN fleet"}, `{"ok":true,"objects":[{"id":"truck1"},{"id":"truck2"}]}`, []string{`"truck1"`, `"truck2"`}},
+		{[]string{"tile38> SCAN fleet", `{"ok":true}`}, `{"id":"truck3"}`, []string{`"truck3"`}},
+		{[]string{"tile38> KEYS *"}, `{"id":"truck1"}`, nil},
+		{nil, `{"command":"set","key":"fleet","id":"truck1"}`, []string{`"truck1"`}},
+		{[]string{"tile38> SCAN fleet"}, `{"ok":true,"count":0}`, nil},
+	}
+	for _, tt := range tests {
+		above := func(n int) (string, bool) {
+			if n < 1 || n > len(tt.above) {
+				return "", false
+			}
+			return tt.above[len(tt.above)-n], true
+		}
+		links := c.detectObjects(tt.line, above)
+		var ids []string
+		for _, link := range links {
+			ids = append(ids, tt.line[link.Start:link.End])
+		}
+		if !reflect.DeepEqual(ids, tt.ids) {
+			t.Errorf("detectObjects(%q) links %q, want %q", tt.line, ids, tt.ids)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package console
+
+import "strings"
+
+// histSearch is the state of a reverse incremental history search.
+type histSearch struct {
+	orig   string // input before the search started
+	query  string
+	idx    int // history index of the current match
+	failed bool
+}
+
+// startSearch begins a reverse-i-search, or finds the next older match when
+// a search is already running.
+func (c *Console) startSearch() {
+	if c.search != nil {
+		c.searchFrom(c.search.idx - 1)
+		return
+	}
+	if !c.terminal.AcceptingInput() {
+		return
+	}
+	c.search = &histSearch{orig: c.terminal.GetInput(), idx: len(c.history)}
+	c.terminal.KeyFilter = c.searchKey
+	c.showSearch()
+}
+
+// searchFrom finds the newest history line at or before idx that contains
+// the query.
+func (c *Console) searchFrom(idx int) {
+	s := c.search
+	for i := idx; i >= 0 && i < len(c.history); i-- {
+		if strings.Contains(c.history[i], s.query) {
+			s.idx, s.failed = i, false
+			c.showSearch()
+			return
+		}
+	}
+	s.failed = true
+	c.showSearch()
+}
+
+func (c *Console) showSearch() {
+	s := c.search
+	prompt := "(reverse-i-search)`" + s.query + "': "
+	if s.failed {
+		prompt = "(failed " + prompt[1:]
+	}
+	c.terminal.Prompt(prompt)
+	if s.idx < len(c.history) {
+		c.terminal.SetInput(c.history[s.idx])
+	} else {
+		c.terminal.SetInput(s.orig)
+	}
+}
+
+// endSearch leaves search mode. When accept is true the matched line stays
+// in the input, otherwise the original input is restored.
+func (c *Console) endSearch(accept bool) {
+	s := c.search
+	c.search = nil
+	c.terminal.KeyFilter = nil
+	c.terminal.Prompt(c.prompt)
+	if accept && s.idx < len(c.history) {
+		c.terminal.SetInput(c.history[s.idx])
+		c.historyIdx = s.idx
+		c.lastInput = s.orig
+	} else {
+		c.terminal.SetInput(s.orig)
+	}
+}
+
+// searchKey handles keys while searching. Any key that isn't part of the
+// search accepts the match and then performs its usual action.
+func (c *Console) searchKey(key string, ch rune) bool {
+	s := c.search
+	if isModifier(key) {
+		// pressing Ctrl or Shift on the way to another key
+		return false
+	}
+	switch {
+	case key == "Ctrl-R":
+		c.searchFrom(s.idx - 1)
+	case key == "Escape" || key == "Ctrl-G":
+		c.endSearch(false)
+	case key == "Backspace":
+		if s.query != "" {
+			q := []rune(s.query)
+			s.query = string(q[:len(q)-1])
+			c.searchFrom(len(c.history) - 1)
+		}
+	case key != "":
+		if len([]rune(key)) == 1 {
+			// a character key, wait for the keypress
+			return false
+		}
+		c.endSearch(true)
+		return false
+	case ch == 13:
+		c.endSearch(true)
+		return false
+	case ch >= 0x20:
+		s.query += string(ch)
+		c.searchFrom(s.idx)
+	}
+	return true
+}
+
+// isModifier reports whether a key name is a modifier key on its own, such
+// as "Shift" or "Ctrl-Control".
+func isModifier(key string) bool {
+	if i := strings.LastIndexByte(key, '-'); i != -1 && i < len(key)-1 {
+		key = key[i+1:]
+	}
+	switch key {
+	case "Control", "Shift", "Alt", "Meta", "AltGraph", "OS":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package console
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/tile38/try/terminal"
+)
+
+// settingDefaults holds every console setting and its default value.
+var settingDefaults = map[string]string{
+	"theme":        "dark",
+	"json":         "pretty",
+	"copyonselect": "off",
+	"zoom":         "0",
+	"renderer":     "auto",
+}
+
+func settingNames() []string {
+	var names []string
+	for name := range settingDefaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *Console) setting(name string) string {
+	value := js.Global.Get("localStorage").Call("getItem", c.service+":setting:"+name).String()
+	if value == "null" {
+		return settingDefaults[name]
+	}
+	return value
+}
+
+// changeSetting validates, stores and applies a setting.
+func (c *Console) changeSetting(name, value string) {
+	if settingDefaults[name] == "" {
+		c.terminal.WriteString("\x1b[31m(error) unknown setting '" + name + "'\x1b[0m\n")
+		return
+	}
+	if !validSetting(name, value) {
+		c.terminal.WriteString("\x1b[31m(error) invalid value '" + value + "' for " + name + "\x1b[0m\n")
+		return
+	}
+	js.Global.Get("localStorage").Call("setItem", c.service+":setting:"+name, value)
+	c.applySetting(name)
+}
+
+func validSetting(name, value string) bool {
+	switch name {
+	case "theme":
+		_, ok := terminal.Themes[value]
+		return ok
+	case "json":
+		return value == "pretty" || value == "raw"
+	case "copyonselect":
+		return value == "on" || value == "off"
+	case "zoom":
+		_, err := strconv.Atoi(value)
+		return err == nil
+	case "renderer":
+		return value == "auto" || value == "canvas" || value == "dom"
+	}
+	return true
+}
+
+func (c *Console) applySettings() {
+	for name := range settingDefaults {
+		c.applySetting(name)
+	}
+}
+
+func (c *Console) applySetting(name string) {
+	value := c.setting(name)
+	if !validSetting(name, value) {
+		value = settingDefaults[name]
+	}
+	switch name {
+	case "theme":
+		c.terminal.SetTheme(terminal.Themes[value])
+	case "copyonselect":
+		c.terminal.CopyOnSelect = value == "on"
+	case "zoom":
+		zoom, _ := strconv.Atoi(value)
+		c.terminal.SetZoom(zoom)
+	case "renderer":
+		switch value {
+		case "canvas":
+			// nil without a 2D canvas, which picks the DOM
+			c.terminal.SetRenderer(terminal.NewCanvasRenderer())
+		case "dom":
+			c.terminal.SetRenderer(terminal.NewDOMRenderer())
+		default:
+			c.terminal.SetRenderer(nil)
+		}
+	}
+}
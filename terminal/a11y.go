@@ -0,0 +1,256 @@
+package terminal
+
+import (
+	"strings"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+const (
+	maxLiveLines = 100                 // lines kept in the live region
+	textModeKey  = "terminal:textmode" // localStorage key of the text mode
+)
+
+// initA11y adds the ARIA live region that announces output, and the
+// element used by the text mode, which shows the output as plain DOM text
+// in place of the renderer.
+func (t *Terminal) initA11y() {
+	doc := js.Global.Get("document")
+	t.live = doc.Call("createElement", "div")
+	t.live.Call("setAttribute", "role", "log")
+	t.live.Call("setAttribute", "aria-live", "polite")
+	t.live.Call("setAttribute", "aria-label", "Terminal output")
+	visuallyHidden(t.live)
+	t.parent.Call("appendChild", t.live)
+
+	t.text = doc.Call("createElement", "pre")
+	t.text.Call("setAttribute", "aria-label", "Terminal output")
+	style := t.text.Get("style")
+	style.Set("position", "absolute")
+	style.Set("top", "0")
+	style.Set("left", "0")
+	style.Set("right", "0")
+	style.Set("bottom", "0")
+	style.Set("margin", "0")
+	style.Set("padding", itoa(pady)+"px "+itoa(padx)+"px")
+	style.Set("overflow", "auto")
+	style.Set("whiteSpace", "pre-wrap")
+	style.Set("wordBreak", "break-all")
+	t.parent.Call("appendChild", t.text)
+
+	storage := js.Global.Get("localStorage")
+	t.textMode = storage != js.Undefined && storage.Call("getItem", textModeKey).String() == "on"
+	t.showTextMode()
+}
+
+// visuallyHidden hides an element from view but not from screen readers.
+func visuallyHidden(el *js.Object) {
+	style := el.Get("style")
+	style.Set("position", "absolute")
+	style.Set("width", "1px")
+	style.Set("height", "1px")
+	style.Set("overflow", "hidden")
+	style.Set("clip", "rect(0 0 0 0)")
+	style.Set("whiteSpace", "nowrap")
+}
+
+// labelInput makes the textarea that receives keys an accessible, labeled
+// input.
+func (t *Terminal) labelInput() {
+	t.textarea.Call("setAttribute", "aria-label", "Terminal input")
+	t.textarea.Call("setAttribute", "autocapitalize", "off")
+	t.textarea.Call("setAttribute", "autocomplete", "off")
+	t.textarea.Call("setAttribute", "spellcheck", "false")
+	t.textarea.Call("setAttribute", "autocorrect", "off")
+}
+
+// syncInput mirrors the prompt and input in the textarea, so that screen
+// readers can read what's being typed.
+func (t *Terminal) syncInput() {
+	if t.textarea == nil {
+		return
+	}
+	label := "Terminal input"
+	if t.acceptInput {
+		if prompt := strings.TrimSpace(stripEscapes(t.prompt)); prompt != "" {
+			label += ", " + prompt
+		}
+	}
+	if t.textarea.Call("getAttribute", "aria-label").String() != label {
+		t.textarea.Call("setAttribute", "aria-label", label)
+	}
+	if t.textarea.Get("value").String() != t.input {
+		t.textarea.Set("value", t.input)
+	}
+	cursor := utf16Len(t.input[:t.cursorIdx])
+	t.textarea.Call("setSelectionRange", cursor, cursor)
+}
+
+// announce queues output for the live region.
+func (t *Terminal) announce(s string) {
+	t.liveText += stripEscapes(s)
+}
+
+// flushLive adds the queued output to the live region. Screen readers read
+// the lines as they are added.
+func (t *Terminal) flushLive() {
+	if t.liveText == "" || t.live == nil {
+		return
+	}
+	lines := strings.Split(t.liveText, "\n")
+	// keep a partial line until it's finished
+	t.liveText = lines[len(lines)-1]
+	doc := js.Global.Get("document")
+	for _, line := range lines[:len(lines)-1] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		div := doc.Call("createElement", "div")
+		div.Set("textContent", line)
+		t.live.Call("appendChild", div)
+	}
+	for t.live.Get("childElementCount").Int() > maxLiveLines {
+		t.live.Call("removeChild", t.live.Get("firstChild"))
+	}
+}
+
+// toggleTextMode switches between the renderer and the DOM text.
+func (t *Terminal) toggleTextMode() {
+	t.textMode = !t.textMode
+	value := "off"
+	if t.textMode {
+		value = "on"
+	}
+	if storage := js.Global.Get("localStorage"); storage != js.Undefined {
+		storage.Call("setItem", textModeKey, value)
+	}
+	t.showTextMode()
+	t.dirty = true
+}
+
+// showTextMode shows either the renderer or the DOM text.
+func (t *Terminal) showTextMode() {
+	display := "none"
+	if t.textMode {
+		display = "block"
+	}
+	t.text.Get("style").Set("display", display)
+	if t.renderer != nil {
+		if t.textMode {
+			t.renderer.element().Get("style").Set("display", "none")
+		} else {
+			t.renderer.element().Get("style").Set("display", "block")
+		}
+	}
+}
+
+// textLines are the lines of the text mode, a text node for each line of
+// the screen. Only the lines that output may have changed are updated.
+type textLines struct {
+	nodes  []*js.Object
+	first  int // absolute number of the first line
+	stable int // absolute number of the first line output may change
+	input  int // absolute number of the line with the input
+	gen    int // outputGen when the lines were updated
+}
+
+// drawText draws the output and input as DOM text.
+func (t *Terminal) drawText() {
+	style := t.text.Get("style")
+	style.Set("color", t.theme.Foreground)
+	style.Set("background", t.theme.Background)
+	style.Set("font", itoa(t.fontSize())+"px "+t.theme.FontFamily)
+	atEnd := t.text.Get("scrollTop").Float()+t.text.Get("clientHeight").Float() >= t.text.Get("scrollHeight").Float()-1
+	tl, scr := &t.textLines, t.screen
+	end := scr.dropped + scr.lines.len()
+	// drop the lines that left the scrollback or were cleared
+	for len(tl.nodes) > 0 && (tl.first < scr.dropped || tl.first+len(tl.nodes) > end) {
+		if tl.first < scr.dropped {
+			t.text.Call("removeChild", tl.nodes[0])
+			tl.nodes = tl.nodes[1:]
+			tl.first++
+		} else {
+			t.text.Call("removeChild", tl.nodes[len(tl.nodes)-1])
+			tl.nodes = tl.nodes[:len(tl.nodes)-1]
+		}
+	}
+	if len(tl.nodes) == 0 {
+		tl.first, tl.stable = scr.dropped, scr.dropped
+	}
+	if tl.nodes == nil || tl.gen != t.outputGen {
+		// lines above the cursor addressing area don't change
+		from := tl.stable
+		if top := scr.dropped + scr.top(); top < from {
+			from = top
+		}
+		if from < tl.first {
+			from = tl.first
+		} else if from > tl.first+len(tl.nodes) {
+			// lines were removed from the end
+			from = tl.first + len(tl.nodes)
+		}
+		doc := js.Global.Get("document")
+		for abs := from; abs < end; abs++ {
+			if abs-tl.first < len(tl.nodes) {
+				t.drawTextLine(abs)
+				continue
+			}
+			node := doc.Call("createTextNode", t.textLine(abs))
+			t.text.Call("appendChild", node)
+			tl.nodes = append(tl.nodes, node)
+		}
+		tl.stable = scr.dropped + scr.top()
+		tl.gen = t.outputGen
+	}
+	// the input changes without output
+	t.drawTextLine(tl.input)
+	if t.acceptInput {
+		tl.input = scr.dropped + scr.row
+		t.drawTextLine(tl.input)
+	}
+	if atEnd {
+		t.text.Set("scrollTop", t.text.Get("scrollHeight"))
+	}
+}
+
+// textLine returns the text of a line of the text mode by its absolute
+// number.
+func (t *Terminal) textLine(abs int) string {
+	return strings.TrimRight(cellText(t.displayLine(abs-t.screen.dropped)), " ") + "\n"
+}
+
+// drawTextLine updates a line of the text mode when it has changed.
+func (t *Terminal) drawTextLine(abs int) {
+	tl := &t.textLines
+	i := abs - tl.first
+	if i < 0 || i >= len(tl.nodes) {
+		return
+	}
+	if text := t.textLine(abs); tl.nodes[i].Get("data").String() != text {
+		tl.nodes[i].Set("data", text)
+	}
+}
+
+// stripEscapes removes escape sequences and control characters other than
+// newlines and tabs.
+func stripEscapes(s string) string {
+	var buf []rune
+	var esc, csi bool
+	for _, ch := range s {
+		switch {
+		case csi:
+			if ch >= 0x40 && ch <= 0x7E {
+				csi = false
+			}
+		case esc:
+			esc = false
+			csi = ch == '['
+		case ch == 0x1B:
+			esc = true
+		case ch < 0x20 && ch != '\n' && ch != '\t':
+		default:
+			buf = append(buf, ch)
+		}
+	}
+	return string(buf)
+}
@@ -0,0 +1,172 @@
+package terminal
+
+import "github.com/gopherjs/gopherjs/js"
+
+const atlasSize = 1024 // width and height of the glyph atlas in device pixels
+
+// canvasRenderer draws on a 2D canvas. Each glyph is drawn once into an
+// offscreen atlas and copied from there, which is much faster than
+// fillText, and only the rows that changed since the last frame are drawn
+// again.
+type canvasRenderer struct {
+	canvas, ctx     *js.Object
+	atlas, atlasCtx *js.Object
+	glyphs          map[glyphKey]int // atlas slot of each glyph
+	slots           int              // slots in use
+	ratio           float64
+	cellW, cellH    float64 // cell size in device pixels
+	slotW, slotH    int
+	family          string
+	size            int
+	prev            [][]frameCell // the rows that are on the canvas
+	theme           Theme
+	focused         bool
+}
+
+type glyphKey struct {
+	text  string
+	flags uint8 // bold and italic
+	color string
+}
+
+// NewCanvasRenderer returns a renderer that draws on a canvas, or nil when
+// the browser has no 2D canvas.
+func NewCanvasRenderer() Renderer {
+	doc := js.Global.Get("document")
+	canvas := doc.Call("createElement", "canvas")
+	ctx := canvas.Call("getContext", "2d")
+	if ctx == nil || ctx == js.Undefined {
+		return nil
+	}
+	atlas := doc.Call("createElement", "canvas")
+	atlas.Set("width", atlasSize)
+	atlas.Set("height", atlasSize)
+	return &canvasRenderer{
+		canvas:   canvas,
+		ctx:      ctx,
+		atlas:    atlas,
+		atlasCtx: atlas.Call("getContext", "2d"),
+	}
+}
+
+func (r *canvasRenderer) element() *js.Object {
+	return r.canvas
+}
+
+func (r *canvasRenderer) resize(width, height, ratio float64, family string, size int) (float64, float64) {
+	r.canvas.Set("width", width*ratio)
+	r.canvas.Set("height", height*ratio)
+	r.canvas.Get("style").Set("width", ftoa(width)+"px")
+	r.canvas.Get("style").Set("height", ftoa(height)+"px")
+	r.ratio, r.family, r.size = ratio, family, size
+
+	r.ctx.Set("font", fontStyle(attr{}, family, float64(size)*ratio))
+	charWidth := r.ctx.Call("measureText", "01234567890123456789").Get("width").Float() / 20 / ratio
+	charHeight := float64(size) + linepad
+	r.cellW, r.cellH = charWidth*ratio, charHeight*ratio
+	// leave room for wide glyphs and ones that overhang their cell
+	r.slotW, r.slotH = int(r.cellW*2)+2, int(r.cellH)+2
+	r.resetAtlas()
+	// resizing cleared the canvas
+	r.prev = nil
+	return charWidth, charHeight
+}
+
+func (r *canvasRenderer) resetAtlas() {
+	r.atlasCtx.Call("clearRect", 0, 0, atlasSize, atlasSize)
+	r.glyphs = make(map[glyphKey]int)
+	r.slots = 0
+}
+
+// glyph returns the position of a glyph in the atlas, drawing it there
+// first when needed. The atlas starts over when it's full. Slots are two
+// cells wide, which fits wide characters.
+func (r *canvasRenderer) glyph(text string, flags uint8, color string) (x, y int) {
+	key := glyphKey{text, flags & (attrBold | attrItalic), color}
+	perRow := atlasSize / r.slotW
+	slot, ok := r.glyphs[key]
+	if !ok {
+		if r.slots == perRow*(atlasSize/r.slotH) {
+			r.resetAtlas()
+		}
+		slot = r.slots
+		r.slots++
+		r.glyphs[key] = slot
+	}
+	x, y = slot%perRow*r.slotW, slot/perRow*r.slotH
+	if !ok {
+		ctx := r.atlasCtx
+		ctx.Call("save")
+		ctx.Call("beginPath")
+		ctx.Call("rect", x, y, r.slotW, r.slotH)
+		ctx.Call("clip")
+		ctx.Set("font", fontStyle(attr{flags: key.flags}, r.family, float64(r.size)*r.ratio))
+		ctx.Set("fillStyle", color)
+		ctx.Call("fillText", text, x, float64(y)+r.cellH-linepad*r.ratio)
+		ctx.Call("restore")
+	}
+	return x, y
+}
+
+func (r *canvasRenderer) draw(f *frame) {
+	full := r.prev == nil || len(r.prev) != len(f.cells) || *f.theme != r.theme || f.focused != r.focused
+	if full {
+		r.ctx.Call("clearRect", 0, 0, r.canvas.Get("width"), r.canvas.Get("height"))
+		r.theme, r.focused = *f.theme, f.focused
+	}
+	for y, row := range f.cells {
+		if !full && sameRow(row, r.prev[y]) {
+			continue
+		}
+		r.drawRow(f, y, !full)
+	}
+	r.prev = f.cells
+}
+
+// drawRow draws a row, clearing it first when clear is set.
+func (r *canvasRenderer) drawRow(f *frame, y int, clear bool) {
+	ctx := r.ctx
+	left, top := float64(int(padx*r.ratio)), float64(int(pady*r.ratio+float64(y)*r.cellH))
+	if clear {
+		ctx.Call("clearRect", 0, top, r.canvas.Get("width"), r.cellH+1)
+	}
+	ctx.Set("globalAlpha", 1)
+	// backgrounds go first so that they don't cover the glyphs that
+	// overhang their cell
+	for x, c := range f.cells[y] {
+		cx := float64(int(left + float64(x)*r.cellW))
+		if _, bg := f.colors(c); bg != "" {
+			ctx.Set("fillStyle", bg)
+			ctx.Call("fillRect", cx, top, r.cellW+0.5*r.ratio, r.cellH+0.5*r.ratio)
+		}
+		if c.mark&markCursor != 0 && !f.focused {
+			ctx.Set("strokeStyle", f.theme.Cursor)
+			ctx.Set("lineWidth", r.ratio)
+			ctx.Call("strokeRect", cx, top, r.cellW+0.5*r.ratio, r.cellH+0.5*r.ratio)
+		}
+	}
+	for x, c := range f.cells[y] {
+		underline := c.attr.has(attrUnderline) || c.mark&markLink != 0
+		if c.ch == 0 && !underline && !c.attr.has(attrStrike) {
+			continue
+		}
+		cx := float64(int(left + float64(x)*r.cellW))
+		fg, _ := f.colors(c)
+		if c.attr.has(attrDim) {
+			ctx.Set("globalAlpha", 0.6)
+		}
+		// the second cell of a wide character has no glyph of its own
+		if c.ch > ' ' && !c.attr.has(attrHidden) {
+			sx, sy := r.glyph(c.text(), c.attr.flags, fg)
+			ctx.Call("drawImage", r.atlas, sx, sy, r.slotW, r.slotH, cx, top, r.slotW, r.slotH)
+		}
+		ctx.Set("fillStyle", fg)
+		if underline {
+			ctx.Call("fillRect", cx, top+r.cellH-linepad*r.ratio+r.ratio, r.cellW+0.5*r.ratio, r.ratio)
+		}
+		if c.attr.has(attrStrike) {
+			ctx.Call("fillRect", cx, top+r.cellH/2, r.cellW+0.5*r.ratio, r.ratio)
+		}
+		ctx.Set("globalAlpha", 1)
+	}
+}
@@ -2,7 +2,11 @@ package terminal
 
 //  http://stackoverflow.com/questions/400212/how-do-i-copy-to-the-clipboard-in-javascript
 
-import "github.com/gopherjs/gopherjs/js"
+import (
+	"strings"
+
+	"github.com/gopherjs/gopherjs/js"
+)
 
 func copyTextToClipboard(text string) bool {
 	textArea := js.Global.Get("document").Call("createElement", "textarea")
@@ -54,3 +58,123 @@ func copyTextToClipboard(text string) bool {
 	js.Global.Get("document").Get("body").Call("removeChild", textArea)
 	return successful
 }
+
+// writeClipboard copies text using the async Clipboard API, falling back to
+// copyTextToClipboard where it's unavailable or refused.
+func writeClipboard(text string) {
+	clipboard := js.Global.Get("navigator").Get("clipboard")
+	if clipboard == js.Undefined || clipboard.Get("writeText") == js.Undefined {
+		copyTextToClipboard(text)
+		return
+	}
+	clipboard.Call("writeText", text).Call("catch", func() {
+		copyTextToClipboard(text)
+	})
+}
+
+// canReadClipboard reports whether the async Clipboard API can read text.
+// Without it, pasting is left to the browser and the textarea paste event,
+// and the touch toolbar has no paste button.
+func canReadClipboard() bool {
+	clipboard := js.Global.Get("navigator").Get("clipboard")
+	return clipboard != js.Undefined && clipboard.Get("readText") != js.Undefined
+}
+
+// copySelection copies the selected text and reports whether there was a
+// selection.
+func (t *Terminal) copySelection() bool {
+	text := t.selectedText()
+	if text == "" {
+		return false
+	}
+	writeClipboard(text)
+	return true
+}
+
+// copyOrCancel copies the selection, or cancels the input when nothing is
+// selected.
+func (t *Terminal) copyOrCancel() {
+	if !t.copySelection() {
+		t.scrollToEndIfNotScrolling()
+		t.cancel()
+	}
+}
+
+// clipPaste is a paste that reads the clipboard. A paste key also fires
+// the browser's paste event, whose text is kept in case reading the
+// clipboard is refused.
+type clipPaste struct {
+	event    string
+	hasEvent bool
+	refused  bool
+	done     bool
+}
+
+// finish pastes the text of a clipPaste once.
+func (p *clipPaste) finish(t *Terminal, text string) {
+	if !p.done {
+		p.done = true
+		t.paste(text)
+	}
+}
+
+// pasteClipboard reads the clipboard and pastes it into the input.
+func (t *Terminal) pasteClipboard() {
+	p := &clipPaste{}
+	t.clip = p
+	js.Global.Call("setTimeout", func() {
+		// the paste event of a key press has fired by now
+		if t.clip == p {
+			t.clip = nil
+		}
+	}, 0)
+	js.Global.Get("navigator").Get("clipboard").Call("readText").Call("then", func(text string) {
+		p.finish(t, text)
+	}).Call("catch", func() {
+		p.refused = true
+		if p.hasEvent {
+			p.finish(t, p.event)
+			return
+		}
+		// leave the textarea focused for the browser's own paste menu
+		t.textarea.Call("focus")
+	})
+}
+
+// pasteEvent pastes the text of the browser's paste event, unless a paste
+// key is reading the clipboard already.
+func (t *Terminal) pasteEvent(text string) {
+	p := t.clip
+	if p == nil {
+		t.paste(text)
+		return
+	}
+	t.clip = nil
+	p.event, p.hasEvent = text, true
+	if p.refused {
+		p.finish(t, text)
+	}
+}
+
+// paste inserts pasted text into the input. With BracketedPaste, the text
+// is inserted as a whole, so a multi-line paste waits for Enter instead of
+// submitting each line as it's typed.
+func (t *Terminal) paste(text string) {
+	if !t.acceptInput || text == "" {
+		return
+	}
+	t.scrollToEnd()
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	text = strings.Replace(text, "\r", "\n", -1)
+	if !t.BracketedPaste {
+		t.appendStr(text)
+		return
+	}
+	text = strings.TrimRight(text, "\n")
+	if strings.Contains(text, "\n") {
+		t.pastedLines = true
+	}
+	t.input = t.input[:t.cursorIdx] + text + t.input[t.cursorIdx:]
+	t.cursorIdx += len(text)
+	t.dirty = true
+}
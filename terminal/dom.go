@@ -0,0 +1,171 @@
+package terminal
+
+import "github.com/gopherjs/gopherjs/js"
+
+// domRenderer draws each row as a div of styled spans and leaves the text
+// rendering to the browser. Only the rows that changed since the last
+// frame are rebuilt, which keeps frames cheap where a large canvas is slow.
+type domRenderer struct {
+	el         *js.Object
+	rows       []*js.Object
+	charWidth  float64
+	charHeight float64
+	prev       [][]frameCell // the rows in the DOM
+	theme      Theme
+	focused    bool
+}
+
+// NewDOMRenderer returns a renderer that draws with DOM elements.
+func NewDOMRenderer() Renderer {
+	el := js.Global.Get("document").Call("createElement", "div")
+	style := el.Get("style")
+	style.Set("overflow", "hidden")
+	style.Set("whiteSpace", "pre")
+	// the terminal has its own selection
+	style.Set("userSelect", "none")
+	style.Set("webkitUserSelect", "none")
+	return &domRenderer{el: el}
+}
+
+func (r *domRenderer) element() *js.Object {
+	return r.el
+}
+
+func (r *domRenderer) resize(width, height, ratio float64, family string, size int) (float64, float64) {
+	style := r.el.Get("style")
+	style.Set("width", ftoa(width)+"px")
+	style.Set("height", ftoa(height)+"px")
+	style.Set("font", fontStyle(attr{}, family, float64(size)))
+	r.charHeight = float64(size) + linepad
+	style.Set("lineHeight", ftoa(r.charHeight)+"px")
+
+	span := js.Global.Get("document").Call("createElement", "span")
+	span.Set("textContent", "01234567890123456789")
+	r.el.Call("appendChild", span)
+	r.charWidth = span.Call("getBoundingClientRect").Get("width").Float() / 20
+	r.el.Call("removeChild", span)
+
+	// the rows are made again on the next frame
+	for _, row := range r.rows {
+		r.el.Call("removeChild", row)
+	}
+	r.rows, r.prev = nil, nil
+	return r.charWidth, r.charHeight
+}
+
+func (r *domRenderer) draw(f *frame) {
+	if len(r.rows) != len(f.cells) {
+		doc := js.Global.Get("document")
+		for _, row := range r.rows {
+			r.el.Call("removeChild", row)
+		}
+		r.rows = make([]*js.Object, len(f.cells))
+		for y := range r.rows {
+			row := doc.Call("createElement", "div")
+			style := row.Get("style")
+			style.Set("position", "absolute")
+			style.Set("left", itoa(padx)+"px")
+			style.Set("top", ftoa(pady+float64(y)*r.charHeight)+"px")
+			style.Set("height", ftoa(r.charHeight)+"px")
+			r.el.Call("appendChild", row)
+			r.rows[y] = row
+		}
+		r.prev = nil
+	}
+	full := r.prev == nil || *f.theme != r.theme || f.focused != r.focused
+	r.theme, r.focused = *f.theme, f.focused
+	for y, row := range f.cells {
+		if !full && sameRow(row, r.prev[y]) {
+			continue
+		}
+		r.drawRow(f, y)
+	}
+	r.prev = f.cells
+}
+
+// drawRow rebuilds a row with a span for each run of cells that look the
+// same.
+func (r *domRenderer) drawRow(f *frame, y int) {
+	el := r.rows[y]
+	el.Set("textContent", "")
+	cells := f.cells[y]
+	// trailing blank cells need no spans
+	n := len(cells)
+	for n > 0 && cells[n-1] == (frameCell{}) {
+		n--
+	}
+	wide := func(i int) bool {
+		return i+1 < len(cells) && cells[i+1].ch == wideTail
+	}
+	doc := js.Global.Get("document")
+	for i := 0; i < n; {
+		j := i + 1
+		if wide(i) {
+			// a wide character gets a span of its own
+			j++
+		}
+		for j < n && !wide(i) && !wide(j) && sameLook(f, cells[i], cells[j]) {
+			j++
+		}
+		var text []byte
+		for _, c := range cells[i:j] {
+			text = append(text, c.text()...)
+		}
+		span := doc.Call("createElement", "span")
+		span.Set("textContent", string(text))
+		r.style(f, span, cells[i])
+		if wide(i) {
+			// keep the columns after it in line, whatever the font's
+			// width of the character
+			span.Get("style").Set("display", "inline-block")
+			span.Get("style").Set("width", ftoa(2*r.charWidth)+"px")
+		}
+		el.Call("appendChild", span)
+		i = j
+	}
+}
+
+// sameLook reports whether two cells can share a span.
+func sameLook(f *frame, a, b frameCell) bool {
+	if a.attr.flags != b.attr.flags || a.mark&markLink != b.mark&markLink || a.mark&markCursor != 0 || b.mark&markCursor != 0 {
+		return false
+	}
+	afg, abg := f.colors(a)
+	bfg, bbg := f.colors(b)
+	return afg == bfg && abg == bbg
+}
+
+// style sets the style of a span from its first cell.
+func (r *domRenderer) style(f *frame, span *js.Object, c frameCell) {
+	style := span.Get("style")
+	fg, bg := f.colors(c)
+	if c.attr.has(attrHidden) {
+		fg = "transparent"
+	}
+	style.Set("color", fg)
+	if bg != "" {
+		style.Set("background", bg)
+	}
+	if c.mark&markCursor != 0 && !f.focused {
+		style.Set("outline", "1px solid "+f.theme.Cursor)
+		style.Set("outlineOffset", "-1px")
+	}
+	if c.attr.has(attrBold) {
+		style.Set("fontWeight", "bold")
+	}
+	if c.attr.has(attrItalic) {
+		style.Set("fontStyle", "italic")
+	}
+	if c.attr.has(attrDim) {
+		style.Set("opacity", "0.6")
+	}
+	underline := c.attr.has(attrUnderline) || c.mark&markLink != 0
+	switch {
+	case underline && c.attr.has(attrStrike):
+		style.Set("textDecoration", "underline line-through")
+	case underline:
+		style.Set("textDecoration", "underline")
+	case c.attr.has(attrStrike):
+		style.Set("textDecoration", "line-through")
+	}
+}
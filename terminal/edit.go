@@ -0,0 +1,112 @@
+package terminal
+
+import "unicode"
+
+func isWordChar(ch byte) bool {
+	return ch >= 0x80 || unicode.IsLetter(rune(ch)) || unicode.IsDigit(rune(ch)) || ch == '_'
+}
+
+// wordStart returns the start of the word before idx.
+func (t *Terminal) wordStart(idx int) int {
+	for idx > 0 && !isWordChar(t.input[idx-1]) {
+		idx--
+	}
+	for idx > 0 && isWordChar(t.input[idx-1]) {
+		idx--
+	}
+	return idx
+}
+
+// wordEnd returns the end of the word after idx.
+func (t *Terminal) wordEnd(idx int) int {
+	for idx < len(t.input) && !isWordChar(t.input[idx]) {
+		idx++
+	}
+	for idx < len(t.input) && isWordChar(t.input[idx]) {
+		idx++
+	}
+	return idx
+}
+
+func (t *Terminal) moveToStart() {
+	if !t.acceptInput {
+		return
+	}
+	t.cursorIdx = 0
+	t.dirty = true
+}
+
+func (t *Terminal) moveToEnd() {
+	if !t.acceptInput {
+		return
+	}
+	t.cursorIdx = len(t.input)
+	t.dirty = true
+}
+
+func (t *Terminal) wordLeft() {
+	if !t.acceptInput {
+		return
+	}
+	t.cursorIdx = t.wordStart(t.cursorIdx)
+	t.dirty = true
+}
+
+func (t *Terminal) wordRight() {
+	if !t.acceptInput {
+		return
+	}
+	t.cursorIdx = t.wordEnd(t.cursorIdx)
+	t.dirty = true
+}
+
+// kill removes the input between start and end and keeps it for yank.
+// Like the other edits, it does nothing while no prompt is shown, as when
+// a command is running, so that the edits don't turn up at the next prompt.
+func (t *Terminal) kill(start, end int) {
+	if !t.acceptInput || start == end {
+		return
+	}
+	t.killed = t.input[start:end]
+	t.input = t.input[:start] + t.input[end:]
+	t.cursorIdx = start
+	t.dirty = true
+}
+
+func (t *Terminal) killToEnd() {
+	t.kill(t.cursorIdx, len(t.input))
+}
+
+func (t *Terminal) killToStart() {
+	t.kill(0, t.cursorIdx)
+}
+
+func (t *Terminal) killWordLeft() {
+	t.kill(t.wordStart(t.cursorIdx), t.cursorIdx)
+}
+
+func (t *Terminal) killWordRight() {
+	t.kill(t.cursorIdx, t.wordEnd(t.cursorIdx))
+}
+
+// yank inserts the most recently killed text.
+func (t *Terminal) yank() {
+	if !t.acceptInput {
+		return
+	}
+	t.input = t.input[:t.cursorIdx] + t.killed + t.input[t.cursorIdx:]
+	t.cursorIdx += len(t.killed)
+	t.dirty = true
+}
+
+// cancel abandons the current input line.
+func (t *Terminal) cancel() {
+	if !t.acceptInput {
+		return
+	}
+	t.WriteString(t.echoInput() + "^C\n")
+	t.input = ""
+	t.cursorIdx = 0
+	t.pastedLines = false
+	t.dirty = true
+}
@@ -0,0 +1,253 @@
+package terminal
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+const (
+	matchColor        = "#664"
+	currentMatchColor = "#db3"
+)
+
+// match is a range of text found by a search.
+type match struct {
+	start, end textPos
+}
+
+// finder is the state of a scrollback search. The search bar is shown over
+// the bottom row while it's open.
+type finder struct {
+	query         string
+	regex         bool
+	caseSensitive bool
+	invalid       bool // the query is not a valid regular expression
+	matches       []match
+	byLine        map[int][]match
+	current       int
+	cur           textPos // start of the current match
+	gen           int     // output generation that was searched
+}
+
+// openFind opens the search bar, or moves to the next match when it's
+// already open.
+func (t *Terminal) openFind() {
+	if t.find != nil {
+		t.findNext(+1)
+		return
+	}
+	t.find = &finder{current: -1}
+	t.dirty = true
+}
+
+func (t *Terminal) closeFind() {
+	t.find = nil
+	t.dirty = true
+}
+
+// findKey handles a keydown while the search bar is open. It returns false
+// for the keys it doesn't use, such as character keys, which are handled by
+// findChar on keypress.
+func (t *Terminal) findKey(key string) bool {
+	f := t.find
+	switch key {
+	case "Escape", "Ctrl-G":
+		t.closeFind()
+	case "Enter", "F3", "Ctrl-F":
+		t.findNext(+1)
+	case "Shift-Enter", "Ctrl-Shift-F":
+		t.findNext(-1)
+	case "Alt-R":
+		f.regex = !f.regex
+		t.updateFind()
+	case "Alt-C":
+		f.caseSensitive = !f.caseSensitive
+		t.updateFind()
+	case "Backspace":
+		if f.query != "" {
+			_, n := utf8.DecodeLastRuneInString(f.query)
+			f.query = f.query[:len(f.query)-n]
+			t.updateFind()
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// findChar adds a typed character to the query.
+func (t *Terminal) findChar(ch rune) {
+	if ch < 0x20 {
+		return
+	}
+	t.find.query += string(ch)
+	t.updateFind()
+}
+
+// updateFind searches the screen again after the query has changed and
+// moves to the match nearest the end of the output.
+func (t *Terminal) updateFind() {
+	t.find.current = -1
+	t.searchScreen()
+	if n := len(t.find.matches); n > 0 {
+		t.find.current = n - 1
+		t.find.cur = t.find.matches[n-1].start
+		t.scrollToMatch()
+	}
+	t.dirty = true
+}
+
+// searchScreen finds all matches of the query in the screen and the input.
+func (t *Terminal) searchScreen() {
+	f := t.find
+	f.gen = t.outputGen
+	f.matches, f.byLine, f.invalid = nil, nil, false
+	if f.query == "" {
+		return
+	}
+	expr := f.query
+	if !f.regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if !f.caseSensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		f.invalid = true
+		return
+	}
+	f.byLine = make(map[int][]match)
+	scr := t.screen
+	for i := 0; i < scr.lines.len(); i++ {
+		text, offsets := cellOffsets(t.displayLine(i))
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			if loc[0] == loc[1] {
+				// skip empty matches
+				continue
+			}
+			start := sort.SearchInts(offsets, loc[0])
+			end := sort.SearchInts(offsets, loc[1])
+			m := match{textPos{scr.dropped + i, start}, textPos{scr.dropped + i, end}}
+			f.matches = append(f.matches, m)
+			f.byLine[m.start.line] = append(f.byLine[m.start.line], m)
+		}
+	}
+	// keep the current match when the output changes
+	f.current = sort.Search(len(f.matches), func(i int) bool {
+		return !f.matches[i].start.less(f.cur)
+	})
+	if f.current == len(f.matches) {
+		f.current = len(f.matches) - 1
+	}
+}
+
+// findNext moves to the next (+1) or previous (-1) match.
+func (t *Terminal) findNext(dir int) {
+	f := t.find
+	n := len(f.matches)
+	if n == 0 {
+		return
+	}
+	f.current = (f.current + dir + n) % n
+	f.cur = f.matches[f.current].start
+	t.scrollToMatch()
+	t.dirty = true
+}
+
+// scrollToMatch scrolls the current match to the middle of the view.
+func (t *Terminal) scrollToMatch() {
+	if t.acceptInput {
+		t.buildInputLine()
+	}
+	row := t.rowForPos(t.find.cur)
+	last := t.totalRows() - 1
+	t.scrollY = float64(last - t.rows + 1 - (row - t.rows/2))
+}
+
+// matchAt returns the mark for a position that's part of a match, or 0.
+func (t *Terminal) matchAt(pos textPos) uint8 {
+	if t.find == nil {
+		return 0
+	}
+	for _, m := range t.find.byLine[pos.line] {
+		if !pos.less(m.start) && pos.less(m.end) {
+			if m.start == t.find.cur {
+				return markCurrentMatch
+			}
+			return markMatch
+		}
+	}
+	return 0
+}
+
+// drawFind shows the search bar over the bottom row while it's open, or
+// removes it.
+func (t *Terminal) drawFind() {
+	f := t.find
+	if f == nil || t.textMode {
+		if t.findBar != nil {
+			t.parent.Call("removeChild", t.findBar)
+			t.findBar = nil
+		}
+		return
+	}
+	doc := js.Global.Get("document")
+	if t.findBar == nil {
+		t.findBar = doc.Call("createElement", "div")
+		t.findBar.Call("setAttribute", "role", "search")
+		t.parent.Call("appendChild", t.findBar)
+	}
+	bar := t.findBar
+	style := bar.Get("style")
+	style.Set("position", "absolute")
+	style.Set("left", itoa(padx)+"px")
+	style.Set("top", ftoa(pady+float64(t.rows-1)*t.charHeight)+"px")
+	style.Set("width", ftoa(float64(t.cols)*t.charWidth)+"px")
+	style.Set("height", ftoa(t.charHeight)+"px")
+	style.Set("lineHeight", ftoa(t.charHeight)+"px")
+	style.Set("font", fontStyle(attr{}, t.theme.FontFamily, float64(t.fontSize())))
+	style.Set("whiteSpace", "pre")
+	style.Set("overflow", "hidden")
+	style.Set("background", t.theme.Background)
+	bar.Set("textContent", "")
+	col := 0
+	put := func(s, color string) *js.Object {
+		span := doc.Call("createElement", "span")
+		span.Set("textContent", s)
+		span.Get("style").Set("color", color)
+		bar.Call("appendChild", span)
+		col += utf8.RuneCountInString(s)
+		return span
+	}
+	put("Find: ", t.theme.Selection)
+	put(f.query, t.theme.Foreground)
+	put(" ", t.theme.Foreground).Get("style").Set("background", t.theme.Cursor)
+	var status string
+	switch {
+	case f.invalid:
+		status = "invalid pattern"
+	case f.query == "":
+		status = ""
+	case len(f.matches) == 0:
+		status = "no matches"
+	default:
+		status = itoa(f.current+1) + " of " + itoa(len(f.matches))
+	}
+	put(" "+status, t.theme.Foreground)
+	toggle := func(label string, on bool) string {
+		if on {
+			return label
+		}
+		return strings.Repeat("-", len(label))
+	}
+	opts := " [" + toggle("regex", f.regex) + "] [" + toggle("case", f.caseSensitive) + "] Alt-R Alt-C Esc"
+	if c := t.cols - len(opts); c > col {
+		put(strings.Repeat(" ", c-col), t.theme.Foreground)
+	}
+	put(opts, t.theme.Selection)
+}
@@ -0,0 +1,160 @@
+package terminal
+
+import (
+	"strings"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// keyNames maps key codes to the names used by the keymap.
+var keyNames = map[int]string{
+	8:  "Backspace",
+	9:  "Tab",
+	13: "Enter",
+	27: "Escape",
+	33: "PageUp",
+	34: "PageDown",
+	35: "End",
+	36: "Home",
+	37: "Left",
+	38: "Up",
+	39: "Right",
+	40: "Down",
+	46: "Delete",
+}
+
+// keyName returns the keymap name for a keydown event, such as "Ctrl-A",
+// "Alt-F" or "Shift-Tab". Shift is only part of the name for keys that
+// don't type a character.
+func keyName(ev *js.Object) string {
+	code := ev.Get("keyCode").Int()
+	name := keyNames[code]
+	if name == "" {
+		// prefer the physical key for letters, since Alt changes the
+		// character on some keyboards
+		if c := ev.Get("code"); c != js.Undefined && strings.HasPrefix(c.String(), "Key") {
+			name = c.String()[3:]
+		} else if code >= 'A' && code <= 'Z' {
+			name = string(rune(code))
+		} else if k := ev.Get("key"); k != js.Undefined {
+			name = k.String()
+		}
+	}
+	var mods string
+	if ev.Get("ctrlKey").Bool() {
+		mods += "Ctrl-"
+	}
+	if ev.Get("altKey").Bool() {
+		mods += "Alt-"
+	}
+	if ev.Get("metaKey").Bool() {
+		mods += "Meta-"
+	}
+	if ev.Get("shiftKey").Bool() && (keyNames[code] != "" || mods != "") {
+		mods += "Shift-"
+	}
+	return mods + name
+}
+
+// binding is an action of the keymap. Actions that edit the input scroll
+// to it first, while others, such as zooming or copying, leave the view
+// where it is.
+type binding struct {
+	action func()
+	edit   bool
+	native bool // the browser's default action runs as well
+}
+
+// Bind binds a key to an action, replacing any existing binding. Keys are
+// named like "Ctrl-A", "Alt-F", "Shift-Tab" or "Home". A nil action removes
+// the binding and leaves the key to the browser.
+func (t *Terminal) Bind(key string, action func()) {
+	t.bind(key, action, true)
+}
+
+// BindView is like Bind for actions that don't edit the input, which leave
+// the view where it is rather than scrolling to the input.
+func (t *Terminal) BindView(key string, action func()) {
+	t.bind(key, action, false)
+}
+
+func (t *Terminal) bind(key string, action func(), edit bool) {
+	if action == nil {
+		delete(t.keymap, key)
+		return
+	}
+	t.keymap[key] = binding{action: action, edit: edit}
+}
+
+// pressKey performs the action bound to a key, if any, and reports
+// whether the browser's default action for the key should be prevented.
+func (t *Terminal) pressKey(key string) bool {
+	b, ok := t.keymap[key]
+	if !ok {
+		return false
+	}
+	if b.edit {
+		t.scrollToEndIfNotScrolling()
+	}
+	b.action()
+	return !b.native
+}
+
+// bindDefaults sets up the default emacs-style keymap.
+func (t *Terminal) bindDefaults() {
+	t.keymap = make(map[string]binding)
+	bind := func(action func(), keys ...string) {
+		for _, key := range keys {
+			t.Bind(key, action)
+		}
+	}
+	view := func(action func(), keys ...string) {
+		for _, key := range keys {
+			t.BindView(key, action)
+		}
+	}
+	bind(t.backspace, "Backspace", "Ctrl-H")
+	bind(t.delete, "Delete", "Ctrl-D")
+	bind(func() { t.arrow(-1) }, "Left", "Ctrl-B")
+	bind(func() { t.arrow(+1) }, "Right")
+	bind(t.moveToStart, "Home", "Ctrl-A")
+	bind(t.moveToEnd, "End", "Ctrl-E")
+	bind(t.wordLeft, "Alt-B", "Ctrl-Left", "Alt-Left")
+	bind(t.wordRight, "Alt-F", "Ctrl-Right", "Alt-Right")
+	bind(t.killToEnd, "Ctrl-K")
+	bind(t.killToStart, "Ctrl-U")
+	bind(t.killWordLeft, "Ctrl-W", "Alt-Backspace")
+	bind(t.killWordRight, "Alt-D")
+	bind(t.yank, "Ctrl-Y")
+	bind(t.newline, "Shift-Enter")
+	view(t.openFind, "Ctrl-F")
+	bind(t.Clear, "Ctrl-L")
+	view(t.toggleTextMode, "Alt-Shift-A")
+	view(func() { t.zoomBy(+1) }, "Ctrl-=", "Ctrl-+", "Ctrl-Shift-+", "Meta-=", "Meta-+", "Meta-Shift-+")
+	view(func() { t.zoomBy(-1) }, "Ctrl--", "Meta--")
+	view(func() { t.zoomBy(0) }, "Ctrl-0", "Meta-0")
+	view(t.copyOrCancel, "Ctrl-C")
+	view(func() { t.copySelection() }, "Meta-C", "Ctrl-Shift-C")
+	if canReadClipboard() {
+		// the browser's paste event fires too, for when reading the
+		// clipboard is refused
+		for _, key := range []string{"Ctrl-V", "Meta-V", "Ctrl-Shift-V"} {
+			t.keymap[key] = binding{action: t.pasteClipboard, edit: true, native: true}
+		}
+	}
+	bind(func() {
+		if t.Up != nil {
+			t.Up()
+		}
+	}, "Up", "Ctrl-P")
+	bind(func() {
+		if t.Down != nil {
+			t.Down()
+		}
+	}, "Down", "Ctrl-N")
+	bind(func() {
+		if t.acceptInput && t.Tab != nil {
+			t.Tab()
+		}
+	}, "Tab")
+}
@@ -0,0 +1,130 @@
+package terminal
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// A Link is a part of a line of output that does something when it's
+// clicked.
+type Link struct {
+	Start, End int // byte offsets in the line
+	Open       func()
+}
+
+// A LinkDetector finds the links in a line of output. Above returns the
+// line n lines above it, or false past the first line, for detectors that
+// depend on earlier output.
+type LinkDetector func(line string, above func(n int) (string, bool)) []Link
+
+const clickSlop = 4 // pixels the mouse may move during a click
+
+var urlPattern = regexp.MustCompile("https?://[^\\s\"'<>`]+")
+
+// DetectURLs finds web addresses, which open in a new tab.
+func DetectURLs(line string, above func(n int) (string, bool)) []Link {
+	var links []Link
+	for _, loc := range urlPattern.FindAllStringIndex(line, -1) {
+		end := loc[1]
+		// leave out the punctuation that ends a sentence or a bracket
+		for end > loc[0] && strings.IndexByte(".,;:!?)]}", line[end-1]) != -1 {
+			end--
+		}
+		url := line[loc[0]:end]
+		links = append(links, Link{Start: loc[0], End: end, Open: func() {
+			js.Global.Call("open", url, "_blank", "noopener")
+		}})
+	}
+	return links
+}
+
+// hoverLink is the link under the mouse, which is underlined.
+type hoverLink struct {
+	match
+	link Link
+}
+
+// lineLinks are the links found in a line of output.
+type lineLinks struct {
+	offsets []int // byte offset of each cell in the line
+	links   []Link
+}
+
+// linkAt returns the link at a position of the output, or nil. The input
+// line has no links.
+func (t *Terminal) linkAt(pos textPos) *hoverLink {
+	scr := t.screen
+	i := pos.line - scr.dropped
+	if len(t.LinkDetectors) == 0 || i < 0 || i >= scr.lines.len() || (t.acceptInput && i == scr.row) {
+		return nil
+	}
+	ll := t.lineLinks(i)
+	if pos.col >= len(ll.offsets) {
+		return nil
+	}
+	off := ll.offsets[pos.col]
+	for _, link := range ll.links {
+		if off >= link.Start && off < link.End {
+			start := textPos{pos.line, sort.SearchInts(ll.offsets, link.Start)}
+			end := textPos{pos.line, sort.SearchInts(ll.offsets, link.End)}
+			return &hoverLink{match{start, end}, link}
+		}
+	}
+	return nil
+}
+
+// lineLinks returns the links in line i of the screen. They're kept until
+// the output changes, since detectors may look at many lines above.
+func (t *Terminal) lineLinks(i int) *lineLinks {
+	scr := t.screen
+	if t.links == nil || t.linksGen != t.outputGen {
+		t.links = make(map[int]*lineLinks)
+		t.linksGen = t.outputGen
+	}
+	if ll := t.links[scr.dropped+i]; ll != nil {
+		return ll
+	}
+	text, offsets := cellOffsets(scr.lines.at(i))
+	above := func(n int) (string, bool) {
+		if n < 0 || i-n < 0 {
+			return "", false
+		}
+		return cellText(scr.lines.at(i - n)), true
+	}
+	ll := &lineLinks{offsets: offsets}
+	for _, detect := range t.LinkDetectors {
+		ll.links = append(ll.links, detect(text, above)...)
+	}
+	t.links[scr.dropped+i] = ll
+	return ll
+}
+
+// hoverAt underlines the link at a position, if any.
+func (t *Terminal) hoverAt(pos textPos) {
+	if t.isHovered(pos) {
+		return
+	}
+	t.setHover(t.linkAt(pos))
+}
+
+func (t *Terminal) setHover(h *hoverLink) {
+	if h == nil && t.hover == nil {
+		return
+	}
+	t.hover = h
+	cursor := ""
+	if h != nil {
+		cursor = "pointer"
+	}
+	t.renderer.element().Get("style").Set("cursor", cursor)
+	t.dirty = true
+}
+
+// isHovered reports whether a position is part of the link under the
+// mouse.
+func (t *Terminal) isHovered(pos textPos) bool {
+	return t.hover != nil && !pos.less(t.hover.start) && pos.less(t.hover.end)
+}
@@ -0,0 +1,161 @@
+package terminal
+
+import "github.com/gopherjs/gopherjs/js"
+
+const (
+	slowFrame      = 25 // milliseconds a frame may take to draw
+	slowFrameCount = 30 // slow frames in a row before the renderer is switched
+)
+
+// Renderer draws the visible rows of the terminal. The terminal builds a
+// frame of cells with the cursor, the selection and the search matches
+// marked on them, and the renderer only has to paint it.
+type Renderer interface {
+	// element returns the element that's drawn into.
+	element() *js.Object
+	// resize sizes the element in CSS pixels for a font and returns the
+	// size of a character cell.
+	resize(width, height, ratio float64, family string, size int) (charWidth, charHeight float64)
+	// draw paints a frame.
+	draw(f *frame)
+}
+
+// Marks of a frame cell.
+const (
+	markCursor = 1 << iota
+	markSelected
+	markMatch
+	markCurrentMatch
+	markLink // the link under the mouse
+)
+
+type frameCell struct {
+	cell
+	mark uint8
+}
+
+// frame holds the visible rows, each as wide as the terminal.
+type frame struct {
+	cells   [][]frameCell
+	theme   *Theme
+	palette *[256]string
+	focused bool // the page has focus, so the cursor is solid
+}
+
+// colors returns the CSS colors of a cell. The background is empty when
+// it's not painted.
+func (f *frame) colors(c frameCell) (fg, bg string) {
+	a, th := c.attr, f.theme
+	fg = a.fg.css(f.palette, th.Foreground)
+	if a.bg != defaultColor {
+		bg = a.bg.css(f.palette, th.Background)
+	}
+	if a.has(attrInverse) {
+		fg, bg = a.bg.css(f.palette, th.Background), a.fg.css(f.palette, th.Foreground)
+	}
+	switch {
+	case c.mark&markCursor != 0:
+		if f.focused {
+			return th.Background, th.Cursor
+		}
+		// the renderer outlines the cell
+	case c.mark&markSelected != 0:
+		return th.Background, th.Selection
+	case c.mark&markCurrentMatch != 0:
+		return th.Background, currentMatchColor
+	case c.mark&markMatch != 0:
+		return fg, matchColor
+	}
+	return fg, bg
+}
+
+// sameRow reports whether a row is unchanged since the last frame.
+func sameRow(a, b []frameCell) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// fontStyle returns the CSS font for a graphic rendition.
+func fontStyle(a attr, family string, size float64) string {
+	var s string
+	if a.has(attrItalic) {
+		s += "italic "
+	}
+	if a.has(attrBold) {
+		s += "bold "
+	}
+	return s + ftoa(size) + "px " + family
+}
+
+// newRenderer picks a renderer by capability: the canvas when the browser
+// has a 2D canvas, otherwise the DOM. measureFrame may switch to the DOM
+// later when the canvas turns out to be slow.
+func newRenderer() Renderer {
+	if r := NewCanvasRenderer(); r != nil {
+		return r
+	}
+	return NewDOMRenderer()
+}
+
+// SetRenderer changes how the terminal is drawn. A nil renderer picks one
+// automatically.
+func (t *Terminal) SetRenderer(r Renderer) {
+	if r == nil {
+		if t.autoRenderer && t.renderer != nil {
+			return
+		}
+		t.autoRenderer = true
+		r = newRenderer()
+	} else {
+		t.autoRenderer = false
+	}
+	t.useRenderer(r)
+}
+
+// useRenderer replaces the element of the current renderer with that of r.
+func (t *Terminal) useRenderer(r Renderer) {
+	if t.renderer != nil {
+		t.parent.Call("removeChild", t.renderer.element())
+	}
+	t.renderer = r
+	t.slowFrames = 0
+	el := r.element()
+	el.Get("style").Set("position", "absolute")
+	el.Get("style").Set("top", "0")
+	el.Get("style").Set("left", "0")
+	t.parent.Call("appendChild", el)
+	t.showTextMode()
+	t.relayout()
+}
+
+// measureFrame switches an automatically picked canvas to the DOM when
+// frames keep taking too long to draw, as with large canvases on slow
+// graphics.
+func (t *Terminal) measureFrame(ms float64) {
+	if _, ok := t.renderer.(*canvasRenderer); !ok || !t.autoRenderer || t.textMode {
+		return
+	}
+	if ms < slowFrame {
+		t.slowFrames = 0
+		return
+	}
+	t.slowFrames++
+	if t.slowFrames >= slowFrameCount {
+		t.useRenderer(NewDOMRenderer())
+	}
+}
+
+// pointerPos returns the position of a mouse event or touch relative to the
+// renderer element.
+func (t *Terminal) pointerPos(p *js.Object) (x, y float64) {
+	rect := t.renderer.element().Call("getBoundingClientRect")
+	return p.Get("clientX").Float() - rect.Get("left").Float(),
+		p.Get("clientY").Float() - rect.Get("top").Float()
+}
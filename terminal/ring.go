@@ -0,0 +1,189 @@
+package terminal
+
+// lineRing is a ring buffer of lines. Once it holds limit lines, adding a
+// line drops the oldest one.
+type lineRing struct {
+	buf   [][]cell
+	head  int
+	count int
+	limit int
+
+	// rows caches the number of rows the lines take when wrapped. The
+	// entry of a line is the rows before it, counted from some earlier
+	// line, so dropping lines from the front doesn't change the others.
+	rows  []int
+	cols  int // width the rows were counted at
+	valid int // lines at the front whose entry is up to date
+}
+
+func (r *lineRing) len() int {
+	return r.count
+}
+
+func (r *lineRing) at(i int) []cell {
+	return r.buf[(r.head+i)%len(r.buf)]
+}
+
+func (r *lineRing) set(i int, line []cell) {
+	r.buf[(r.head+i)%len(r.buf)] = line
+	if r.valid > i+1 {
+		r.valid = i + 1
+	}
+}
+
+// linear returns the lines in order.
+func (r *lineRing) linear() [][]cell {
+	lines := make([][]cell, r.count)
+	for i := range lines {
+		lines[i] = r.at(i)
+	}
+	return lines
+}
+
+// push adds a line to the end and returns true when the first line was
+// dropped to make room.
+func (r *lineRing) push(line []cell) bool {
+	if r.count == len(r.buf) {
+		if len(r.buf) >= r.limit {
+			r.buf[r.head] = line
+			r.head = (r.head + 1) % len(r.buf)
+			if r.valid > 0 {
+				r.valid--
+			}
+			return true
+		}
+		size := len(r.buf) * 2
+		if size < 64 {
+			size = 64
+		}
+		if size > r.limit {
+			size = r.limit
+		}
+		buf := make([][]cell, size)
+		copy(buf, r.linear())
+		r.buf, r.head = buf, 0
+		r.rows, r.valid = make([]int, size), 0
+	}
+	r.buf[(r.head+r.count)%len(r.buf)] = line
+	r.count++
+	return false
+}
+
+// truncate drops all lines after the first n.
+func (r *lineRing) truncate(n int) {
+	for i := n; i < r.count; i++ {
+		r.set(i, nil)
+	}
+	r.count = n
+	if r.valid > n {
+		r.valid = n
+	}
+}
+
+// dropFront drops the first n lines.
+func (r *lineRing) dropFront(n int) {
+	for i := 0; i < n; i++ {
+		r.set(i, nil)
+	}
+	r.head = (r.head + n) % len(r.buf)
+	r.count -= n
+	r.valid -= n
+	if r.valid < 0 {
+		r.valid = 0
+	}
+}
+
+// setLimit changes the maximum number of lines, and returns the number of
+// lines that were dropped to fit.
+func (r *lineRing) setLimit(limit int) int {
+	if limit < 1 {
+		limit = 1
+	}
+	r.limit = limit
+	var dropped int
+	if r.count > limit {
+		dropped = r.count - limit
+		r.dropFront(dropped)
+	}
+	if len(r.buf) > limit {
+		buf := make([][]cell, limit)
+		copy(buf, r.linear())
+		r.buf, r.head = buf, 0
+		r.rows, r.valid = make([]int, limit), 0
+	}
+	return dropped
+}
+
+// rowsBefore returns the number of rows the lines before line i take when
+// wrapped at cols. Only the lines that changed since the last call are
+// counted again.
+func (r *lineRing) rowsBefore(i, cols int) int {
+	if i <= 0 || r.count == 0 {
+		return 0
+	}
+	if i >= r.count {
+		last := r.count - 1
+		return r.rowsBefore(last, cols) + lineRows(r.at(last), cols)
+	}
+	if cols != r.cols {
+		r.cols, r.valid = cols, 0
+	}
+	slot := func(j int) int {
+		return (r.head + j) % len(r.buf)
+	}
+	if r.valid == 0 {
+		r.rows[slot(0)] = 0
+		r.valid = 1
+	}
+	for ; r.valid <= i; r.valid++ {
+		j := r.valid
+		r.rows[slot(j)] = r.rows[slot(j-1)] + lineRows(r.at(j-1), cols)
+	}
+	return r.rows[slot(i)] - r.rows[slot(0)]
+}
+
+// lineRows returns the number of rows a line takes when wrapped at cols.
+func lineRows(line []cell, cols int) int {
+	if len(line) <= cols {
+		return 1
+	}
+	return cellRow(line, len(line)-1, cols) + 1
+}
+
+// cellRow returns the row of cell j of line wrapped at cols, counted from
+// the first row of the line. Cells past the end of the line are narrow.
+func cellRow(line []cell, j, cols int) int {
+	if j < cols-1 {
+		return 0
+	}
+	w := wrapper{cols: cols}
+	row := 0
+	for k := 0; k <= j; k++ {
+		row, _ = w.place(isWideHead(line, k))
+	}
+	return row
+}
+
+// wrapper places the cells of a line in rows of cols cells. A wide
+// character that would start in the last column moves to the next row and
+// leaves that column empty, so it is never split.
+type wrapper struct {
+	cols     int
+	row, col int
+}
+
+// place returns the row and column of the next cell.
+func (w *wrapper) place(wide bool) (row, col int) {
+	if w.col >= w.cols || (wide && w.col == w.cols-1 && w.cols > 1) {
+		w.row, w.col = w.row+1, 0
+	}
+	row, col = w.row, w.col
+	w.col++
+	return row, col
+}
+
+// isWideHead reports whether cell j of line is the first cell of a wide
+// character.
+func isWideHead(line []cell, j int) bool {
+	return j+1 < len(line) && line[j+1].ch == wideTail
+}
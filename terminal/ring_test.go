@@ -0,0 +1,102 @@
+package terminal
+
+import "testing"
+
+// countRows counts the rows before each line without the cache.
+func countRows(r *lineRing, cols int) []int {
+	rows := make([]int, r.len()+1)
+	for i := 0; i < r.len(); i++ {
+		rows[i+1] = rows[i] + lineRows(r.at(i), cols)
+	}
+	return rows
+}
+
+func TestLineRingRows(t *testing.T) {
+	line := func(n int) []cell {
+		return make([]cell, n)
+	}
+	tests := []struct {
+		name string
+		edit func(r *lineRing)
+	}{
+		{"push", func(r *lineRing) {
+			r.push(line(25))
+		}},
+		{"push past the limit", func(r *lineRing) {
+			for i := 0; i < 10; i++ {
+				r.push(line(i * 7))
+			}
+		}},
+		{"set", func(r *lineRing) {
+			r.set(r.len()-2, line(40))
+		}},
+		{"set at the front", func(r *lineRing) {
+			r.set(0, nil)
+		}},
+		{"truncate", func(r *lineRing) {
+			r.truncate(r.len() - 3)
+		}},
+		{"drop front", func(r *lineRing) {
+			r.dropFront(2)
+		}},
+		{"shrink", func(r *lineRing) {
+			r.setLimit(r.limit - 3)
+		}},
+		{"grow", func(r *lineRing) {
+			r.setLimit(r.limit + 20)
+			r.push(line(31))
+		}},
+	}
+	r := &lineRing{}
+	r.setLimit(16)
+	for i := 0; i < 12; i++ {
+		r.push(line(i * 5))
+	}
+	for _, tt := range tests {
+		tt.edit(r)
+		for _, cols := range []int{10, 10, 7} {
+			want := countRows(r, cols)
+			for i := len(want) - 1; i >= 0; i-- {
+				if got := r.rowsBefore(i, cols); got != want[i] {
+					t.Errorf("%s: rowsBefore(%d, %d) = %d, want %d", tt.name, i, cols, got, want[i])
+				}
+			}
+		}
+		// leave the cache counted at the width of the next edit
+		r.rowsBefore(r.len(), 10)
+	}
+}
+
+func TestLineRingPush(t *testing.T) {
+	tests := []struct {
+		limit, pushes int
+		dropped       int
+	}{
+		{4, 3, 0},
+		{4, 4, 0},
+		{4, 10, 6},
+		{100, 70, 0},
+		{100, 150, 50},
+	}
+	for _, tt := range tests {
+		r := &lineRing{}
+		r.setLimit(tt.limit)
+		dropped := 0
+		for i := 0; i < tt.pushes; i++ {
+			if r.push([]cell{{ch: rune('a' + i%26)}}) {
+				dropped++
+			}
+		}
+		if dropped != tt.dropped {
+			t.Errorf("limit %d, %d pushes: dropped %d, want %d", tt.limit, tt.pushes, dropped, tt.dropped)
+		}
+		if r.len() != tt.pushes-tt.dropped {
+			t.Errorf("limit %d, %d pushes: len %d, want %d", tt.limit, tt.pushes, r.len(), tt.pushes-tt.dropped)
+		}
+		for i := 0; i < r.len(); i++ {
+			if want := rune('a' + (i+dropped)%26); r.at(i)[0].ch != want {
+				t.Errorf("limit %d, %d pushes: line %d is %q, want %q", tt.limit, tt.pushes, i, r.at(i)[0].ch, want)
+			}
+		}
+	}
+}
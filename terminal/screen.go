@@ -0,0 +1,318 @@
+package terminal
+
+import (
+	"strconv"
+	"strings"
+)
+
+// cell is a single character on the screen along with its rendition. A zero
+// ch is an empty cell, which is drawn as a space. Wide characters take two
+// cells, the second of which is a wideTail, and runes that are drawn on
+// top of a character, such as combining marks, are kept in comb.
+type cell struct {
+	ch   rune
+	comb string
+	attr attr
+}
+
+// text returns the characters of a cell.
+func (c cell) text() string {
+	switch c.ch {
+	case 0:
+		return " "
+	case wideTail:
+		return ""
+	}
+	return string(c.ch) + c.comb
+}
+
+// screen is a VT100-style model of the terminal output. Lines are logical
+// lines which are wrapped to the terminal width when drawn. Cursor
+// addressing is relative to the last height lines, which is the part of the
+// output that fits in the terminal. Lines beyond the scrollback limit are
+// dropped.
+type screen struct {
+	lines      lineRing
+	row, col   int
+	attr       attr
+	height     int
+	scrollback int
+	dropped    int // total number of lines dropped
+
+	savedRow, savedCol int
+	savedAttr          attr
+
+	state int    // parser state
+	seq   []byte // escape sequence being parsed
+}
+
+// Parser states.
+const (
+	stateGround = iota
+	stateESC
+	stateCSI
+)
+
+func newScreen(scrollback int) *screen {
+	s := &screen{height: 24, scrollback: scrollback}
+	s.lines.setLimit(s.scrollback + s.height)
+	s.lines.push(nil)
+	return s
+}
+
+func (s *screen) reset() {
+	height, dropped := s.height, s.dropped+s.lines.len()
+	*s = *newScreen(s.scrollback)
+	s.dropped = dropped
+	s.resize(height)
+}
+
+// resize changes the number of lines used for cursor addressing.
+func (s *screen) resize(height int) {
+	s.height = height
+	s.setLimit()
+}
+
+func (s *screen) setScrollback(scrollback int) {
+	s.scrollback = scrollback
+	s.setLimit()
+}
+
+func (s *screen) setLimit() {
+	n := s.lines.setLimit(s.scrollback + s.height)
+	s.dropped += n
+	s.row -= n
+	if s.row < 0 {
+		s.row, s.col = 0, 0
+	}
+}
+
+// top returns the index of the first line that cursor addressing is
+// relative to.
+func (s *screen) top() int {
+	if s.lines.len() > s.height {
+		return s.lines.len() - s.height
+	}
+	return 0
+}
+
+// write parses output and applies it to the screen.
+func (s *screen) write(str string) {
+	for _, ch := range str {
+		switch s.state {
+		case stateGround:
+			s.ground(ch)
+		case stateESC:
+			s.state = stateGround
+			switch ch {
+			case '[':
+				s.state = stateCSI
+				s.seq = s.seq[:0]
+			case '7':
+				s.saveCursor()
+			case '8':
+				s.restoreCursor()
+			case 'c':
+				s.reset()
+			}
+		case stateCSI:
+			if ch >= 0x40 && ch <= 0x7E {
+				s.state = stateGround
+				s.csi(string(s.seq), byte(ch))
+			} else if ch < 0x20 || ch > 0x7E {
+				// invalid sequence
+				s.state = stateGround
+			} else {
+				s.seq = append(s.seq, byte(ch))
+			}
+		}
+	}
+}
+
+func (s *screen) ground(ch rune) {
+	switch ch {
+	case 0x1B:
+		s.state = stateESC
+	case '\r':
+		s.col = 0
+	case '\n':
+		s.col = 0
+		s.moveTo(s.row+1, 0)
+	case '\b':
+		if s.col > 0 {
+			s.col--
+		}
+	case '\t':
+		s.col = (s.col/8 + 1) * 8
+	case 0x07:
+		// bell
+	default:
+		if ch < 0x20 {
+			return
+		}
+		s.put(ch)
+	}
+}
+
+// put writes a character at the cursor and advances the cursor. Runes
+// that belong to the character before the cursor are added to it.
+func (s *screen) put(ch rune) {
+	line := s.lines.at(s.row)
+	if head, ok := joinsCell(line, s.col, ch); ok {
+		line, s.col = joinCell(line, head, s.col, ch)
+		s.lines.set(s.row, line)
+		return
+	}
+	w := runeWidth(ch)
+	if w == 0 {
+		// nothing to draw it on
+		return
+	}
+	for len(line) < s.col+w {
+		line = append(line, cell{})
+	}
+	// overwriting half of a wide character erases the other half
+	if line[s.col].ch == wideTail && s.col > 0 {
+		line[s.col-1] = cell{}
+	}
+	if end := s.col + w; end < len(line) && line[end].ch == wideTail {
+		line[end] = cell{}
+	}
+	line[s.col] = cell{ch: ch, attr: s.attr}
+	if w == 2 {
+		line[s.col+1] = cell{ch: wideTail, attr: s.attr}
+	}
+	s.lines.set(s.row, line)
+	s.col += w
+}
+
+// moveTo moves the cursor, adding lines to the end of the screen as
+// needed.
+func (s *screen) moveTo(row, col int) {
+	if row < 0 {
+		row = 0
+	}
+	if col < 0 {
+		col = 0
+	}
+	for s.lines.len() <= row {
+		if s.lines.push(nil) {
+			s.dropped++
+			row--
+		}
+	}
+	s.row, s.col = row, col
+}
+
+func (s *screen) saveCursor() {
+	s.savedRow, s.savedCol, s.savedAttr = s.row-s.top(), s.col, s.attr
+}
+
+func (s *screen) restoreCursor() {
+	s.moveTo(s.top()+s.savedRow, s.savedCol)
+	s.attr = s.savedAttr
+}
+
+// csi handles a control sequence, such as "2" and 'K' for "\x1b[2K".
+func (s *screen) csi(params string, final byte) {
+	if strings.HasPrefix(params, "?") {
+		// private modes are not supported
+		return
+	}
+	var ps []int
+	for _, p := range strings.Split(params, ";") {
+		n, _ := strconv.Atoi(p)
+		ps = append(ps, n)
+	}
+	// n returns parameter i, or def when it's missing or zero.
+	n := func(i, def int) int {
+		if i < len(ps) && ps[i] > 0 {
+			return ps[i]
+		}
+		return def
+	}
+	top := s.top()
+	switch final {
+	case 'm':
+		s.attr.applySGR(params)
+	case 'A': // cursor up
+		row := s.row - n(0, 1)
+		if row < top {
+			row = top
+		}
+		s.moveTo(row, s.col)
+	case 'B': // cursor down
+		s.moveTo(s.row+n(0, 1), s.col)
+	case 'C': // cursor forward
+		s.moveTo(s.row, s.col+n(0, 1))
+	case 'D': // cursor back
+		s.moveTo(s.row, s.col-n(0, 1))
+	case 'E': // cursor next line
+		s.moveTo(s.row+n(0, 1), 0)
+	case 'F': // cursor previous line
+		row := s.row - n(0, 1)
+		if row < top {
+			row = top
+		}
+		s.moveTo(row, 0)
+	case 'G': // cursor horizontal absolute
+		s.moveTo(s.row, n(0, 1)-1)
+	case 'd': // line position absolute
+		s.moveTo(top+n(0, 1)-1, s.col)
+	case 'H', 'f': // cursor position
+		s.moveTo(top+n(0, 1)-1, n(1, 1)-1)
+	case 'J': // erase in display
+		switch ps[0] {
+		case 0:
+			s.eraseLine(0)
+			s.lines.truncate(s.row + 1)
+		case 1:
+			for i := top; i < s.row; i++ {
+				s.lines.set(i, nil)
+			}
+			s.eraseLine(1)
+		case 2:
+			for i := top; i < s.lines.len(); i++ {
+				s.lines.set(i, nil)
+			}
+		case 3:
+			// erase the scrollback
+			s.lines.dropFront(top)
+			s.dropped += top
+			s.row -= top
+		}
+	case 'K': // erase in line
+		s.eraseLine(ps[0])
+	case 's':
+		s.saveCursor()
+	case 'u':
+		s.restoreCursor()
+	}
+}
+
+// eraseLine erases from the cursor to the end of the line (0), from the
+// start of the line to the cursor (1), or the whole line (2).
+func (s *screen) eraseLine(mode int) {
+	line := s.lines.at(s.row)
+	switch mode {
+	case 0:
+		if s.col < len(line) {
+			s.lines.set(s.row, line[:s.col])
+		}
+	case 1:
+		for i := 0; i <= s.col && i < len(line); i++ {
+			line[i] = cell{}
+		}
+	case 2:
+		s.lines.set(s.row, nil)
+	}
+}
+
+// text returns the screen contents without any rendition.
+func (s *screen) text() string {
+	lines := make([]string, s.lines.len())
+	for i := range lines {
+		lines[i] = cellText(s.lines.at(i))
+	}
+	return strings.Join(lines, "\n")
+}
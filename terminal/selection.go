@@ -0,0 +1,154 @@
+package terminal
+
+import "strings"
+
+// Selection units. A double click selects words and a triple click selects
+// lines, and dragging afterwards extends the selection by the same unit.
+const (
+	selectChars = iota
+	selectWords
+	selectLines
+)
+
+// defaultWordDelimiters keeps IDs like "truck-1.a" and numbers like
+// "-112.07" together, but splits coordinate pairs and JSON.
+const defaultWordDelimiters = " \t()[]{}<>\"'`,;:=|"
+
+// selection returns the ordered start and end of the selection.
+func (t *Terminal) selection() (start, end textPos) {
+	if t.selHead.less(t.selAnchor) {
+		return t.selHead, t.selAnchor
+	}
+	return t.selAnchor, t.selHead
+}
+
+func (t *Terminal) clearSelection() {
+	t.selAnchor, t.selHead = textPos{}, textPos{}
+	t.dirty = true
+}
+
+// selectedText returns the selected text.
+func (t *Terminal) selectedText() string {
+	start, end := t.selection()
+	if start == end {
+		return ""
+	}
+	scr := t.screen
+	var buf []byte
+	for n := start.line; n <= end.line; n++ {
+		i := n - scr.dropped
+		if i < 0 {
+			continue
+		} else if i >= scr.lines.len() {
+			break
+		}
+		line := t.displayLine(i)
+		from, to := 0, len(line)
+		if n == start.line {
+			from = start.col
+		}
+		if n == end.line && end.col < to {
+			to = end.col
+		}
+		for j := from; j < to; j++ {
+			buf = append(buf, line[j].text()...)
+		}
+		if n != end.line {
+			buf = append(buf, '\n')
+		}
+	}
+	return string(buf)
+}
+
+// unitAt returns the word or line at a position.
+func (t *Terminal) unitAt(pos textPos, unit int) match {
+	i := pos.line - t.screen.dropped
+	if i < 0 || i >= t.screen.lines.len() {
+		return match{pos, pos}
+	}
+	line := t.displayLine(i)
+	if unit == selectLines {
+		return match{textPos{pos.line, 0}, textPos{pos.line, len(line)}}
+	}
+	isWord := func(j int) bool {
+		return line[j].ch != 0 && !strings.ContainsRune(t.WordDelimiters, line[j].ch)
+	}
+	if pos.col >= len(line) || !isWord(pos.col) {
+		end := pos.col + 1
+		if end > len(line) {
+			end = len(line)
+		}
+		return match{pos, textPos{pos.line, end}}
+	}
+	start, end := pos.col, pos.col
+	for start > 0 && isWord(start-1) {
+		start--
+	}
+	for end < len(line) && isWord(end) {
+		end++
+	}
+	return match{textPos{pos.line, start}, textPos{pos.line, end}}
+}
+
+// selectStart starts a selection at a position. Clicks is the click count
+// of the mouse press, and extend keeps the current anchor as for a
+// shift-click.
+func (t *Terminal) selectStart(pos textPos, clicks int, extend bool) {
+	t.dirty = true
+	if extend && t.selAnchor != t.selHead {
+		t.selUnit = selectChars
+		t.selOrigin = match{t.selAnchor, t.selAnchor}
+		t.selHead = pos
+		return
+	}
+	switch {
+	case clicks >= 3:
+		t.selUnit = selectLines
+	case clicks == 2:
+		t.selUnit = selectWords
+	default:
+		t.selUnit = selectChars
+	}
+	if t.selUnit == selectChars {
+		t.selOrigin = match{pos, pos}
+	} else {
+		t.selOrigin = t.unitAt(pos, t.selUnit)
+	}
+	t.selAnchor, t.selHead = t.selOrigin.start, t.selOrigin.end
+}
+
+// selectTo extends the selection to a position while dragging.
+func (t *Terminal) selectTo(pos textPos) {
+	t.dirty = true
+	if t.selUnit == selectChars {
+		t.selHead = pos
+		return
+	}
+	unit := t.unitAt(pos, t.selUnit)
+	if pos.less(t.selOrigin.start) {
+		t.selAnchor, t.selHead = t.selOrigin.end, unit.start
+	} else {
+		t.selAnchor, t.selHead = t.selOrigin.start, unit.end
+	}
+}
+
+// dragScroll scrolls the view while a selection is dragged above or below
+// the terminal, and extends the selection to the edge.
+func (t *Terminal) dragScroll() {
+	if !t.mdown || t.dragDir == 0 {
+		return
+	}
+	t.scrollY -= float64(t.dragDir)
+	row := t.rowOffset - t.dragDir
+	if t.dragDir > 0 {
+		row = t.rowOffset + t.rows
+	}
+	if row < 0 {
+		row = 0
+	}
+	col := 0
+	if t.dragDir > 0 {
+		col = t.cols
+	}
+	t.selectTo(t.posForRowCol(row, col))
+}
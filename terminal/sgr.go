@@ -0,0 +1,162 @@
+package terminal
+
+import (
+	"strconv"
+	"strings"
+)
+
+// color is a terminal color. The zero value is the default color, palette
+// colors have the palette flag set, and truecolor values have the rgb flag
+// set with the color in the low 24 bits.
+type color uint32
+
+const (
+	defaultColor color = 0
+	paletteFlag  color = 1 << 24
+	rgbFlag      color = 1 << 25
+)
+
+func paletteColor(n int) color {
+	return paletteFlag | color(n&0xFF)
+}
+
+func rgbColor(r, g, b int) color {
+	return rgbFlag | color((r&0xFF)<<16|(g&0xFF)<<8|(b&0xFF))
+}
+
+const (
+	attrBold = 1 << iota
+	attrDim
+	attrItalic
+	attrUnderline
+	attrInverse
+	attrHidden
+	attrStrike
+)
+
+// attr holds the graphic rendition of a cell.
+type attr struct {
+	fg, bg color
+	flags  uint8
+}
+
+func (a attr) has(flag uint8) bool {
+	return a.flags&flag != 0
+}
+
+// palette holds the CSS colors for the 256 indexed colors. The first 16 are
+// the standard and bright ANSI colors, which are replaced by the theme,
+// followed by a 6x6x6 color cube and a grayscale ramp.
+var palette [256]string
+
+func init() {
+	copy(palette[:], []string{
+		"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white",
+		"#666", "#f55", "#5f5", "#ff5", "#55f", "#f5f", "#5ff", "#fff",
+	})
+	levels := []int{0, 95, 135, 175, 215, 255}
+	for i := 0; i < 216; i++ {
+		palette[16+i] = rgbCSS(levels[i/36], levels[i/6%6], levels[i%6])
+	}
+	for i := 0; i < 24; i++ {
+		v := 8 + i*10
+		palette[232+i] = rgbCSS(v, v, v)
+	}
+}
+
+func rgbCSS(r, g, b int) string {
+	return "rgb(" + strconv.Itoa(r) + "," + strconv.Itoa(g) + "," + strconv.Itoa(b) + ")"
+}
+
+// css returns the CSS color using the palette, or def for the default
+// color.
+func (c color) css(pal *[256]string, def string) string {
+	switch {
+	case c&rgbFlag != 0:
+		return rgbCSS(int(c>>16&0xFF), int(c>>8&0xFF), int(c&0xFF))
+	case c&paletteFlag != 0:
+		return pal[c&0xFF]
+	}
+	return def
+}
+
+// applySGR applies the parameters of a "Select Graphic Rendition" sequence,
+// such as "1;31" from "\x1b[1;31m".
+func (a *attr) applySGR(params string) {
+	var ps []int
+	for _, p := range strings.Split(params, ";") {
+		n, _ := strconv.Atoi(p) // an empty parameter means 0
+		ps = append(ps, n)
+	}
+	for i := 0; i < len(ps); i++ {
+		switch p := ps[i]; {
+		case p == 0:
+			*a = attr{}
+		case p == 1:
+			a.flags |= attrBold
+		case p == 2:
+			a.flags |= attrDim
+		case p == 3:
+			a.flags |= attrItalic
+		case p == 4:
+			a.flags |= attrUnderline
+		case p == 7:
+			a.flags |= attrInverse
+		case p == 8:
+			a.flags |= attrHidden
+		case p == 9:
+			a.flags |= attrStrike
+		case p == 21 || p == 22:
+			a.flags &^= attrBold | attrDim
+		case p == 23:
+			a.flags &^= attrItalic
+		case p == 24:
+			a.flags &^= attrUnderline
+		case p == 27:
+			a.flags &^= attrInverse
+		case p == 28:
+			a.flags &^= attrHidden
+		case p == 29:
+			a.flags &^= attrStrike
+		case p >= 30 && p <= 37:
+			a.fg = paletteColor(p - 30)
+		case p == 38 || p == 48:
+			var c color
+			c, i = extendedColor(ps, i)
+			if p == 38 {
+				a.fg = c
+			} else {
+				a.bg = c
+			}
+		case p == 39:
+			a.fg = defaultColor
+		case p >= 40 && p <= 47:
+			a.bg = paletteColor(p - 40)
+		case p == 49:
+			a.bg = defaultColor
+		case p >= 90 && p <= 97:
+			a.fg = paletteColor(p - 90 + 8)
+		case p >= 100 && p <= 107:
+			a.bg = paletteColor(p - 100 + 8)
+		}
+	}
+}
+
+// extendedColor reads a "38;5;n" or "38;2;r;g;b" color starting at ps[i] and
+// returns the color and the index of the last parameter used.
+func extendedColor(ps []int, i int) (color, int) {
+	if i+1 >= len(ps) {
+		return defaultColor, i
+	}
+	switch ps[i+1] {
+	case 5:
+		if i+2 < len(ps) {
+			return paletteColor(ps[i+2]), i + 2
+		}
+	case 2:
+		if i+4 < len(ps) {
+			return rgbColor(ps[i+2], ps[i+3], ps[i+4]), i + 4
+		}
+	}
+	return defaultColor, len(ps)
+}
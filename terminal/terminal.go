@@ -1,39 +1,19 @@
 package terminal
 
 import (
-	"bytes"
 	"time"
 
 	"github.com/gopherjs/gopherjs/js"
 )
 
 const (
-	fontSize        = 12
-	padx            = 8
-	pady            = 8
-	baseColor       = "#bbb"
-	selectionColor  = "#7be"
-	backgroundColor = "#000"
-	linepad         = 3
-	font            = "Monaco, Consolas, Menlo, Monospace, \"Times New Roman\", Times"
-	retina          = true
-	dbgBorder       = false
-	clickDuration   = time.Millisecond * 100
-)
-
-const (
-	clear   = "[0m"
-	bright  = "[1m"
-	dim     = "[2m"
-	black   = "[30m"
-	red     = "[31m"
-	green   = "[32m"
-	yellow  = "[33m"
-	blue    = "[34m"
-	magenta = "[35m"
-	cyan    = "[36m"
-	white   = "[37m"
-	dgray   = "[90m"
+	padx          = 8
+	pady          = 8
+	linepad       = 3
+	defaultFont   = "Monaco, Consolas, Menlo, Monospace, \"Times New Roman\", Times"
+	retina        = true
+	clickDuration = time.Millisecond * 100
+	scrollback    = 5000
 )
 
 type Duration float64
@@ -51,16 +31,18 @@ func randi() int {
 }
 
 type Terminal struct {
-	parent, canvas *js.Object
-	ctx            *js.Object
+	parent         *js.Object
 	textarea       *js.Object
+	renderer       Renderer
+	autoRenderer   bool // the renderer was picked automatically
+	slowFrames     int  // frames in a row that were slow to draw
 	width, height  float64
 	ratio          float64
 	timestamp      Duration
 	cols, rows     int
 	charWidth      float64
 	charHeight     float64
-	buffer         string
+	screen         *screen
 	dirty          bool
 	input          string
 	acceptInput    bool
@@ -70,30 +52,70 @@ type Terminal struct {
 	cancelScroll   bool
 	Input          func(s string)
 	Up, Down       func()
-	color          string
-	bright         bool
 	mdown          bool
+	mdownX, mdownY float64 // where the mouse was pressed
+	mdetail        int     // click count of the mouse press
 	rowOffset      int
 	maxRowOffset   int
-	mdownrow       int
-	mdowncol       int
-	mmoverow       int
-	mmovecol       int
 	mtime          time.Time
-	selStart       int
-	selEnd         int
-	selectedString *bytes.Buffer
+	selAnchor      textPos
+	selHead        textPos
+	selUnit        int
+	selOrigin      match // the word or line where the selection started
+	dragDir        int   // auto-scroll direction while dragging
+	WordDelimiters string
+	CopyOnSelect   bool
+	BracketedPaste bool
+	inputLine      []cell
+	inputCursor    int
 	scrollInt      *js.Object
 	scrolling      bool
 	pasted         bool
+	pastedLines    bool       // the input has lines from a bracketed paste
+	clip           *clipPaste // a paste reading the clipboard
+	theme          Theme
+	palette        [256]string
+	zoom           int
+	ZoomChanged    func(zoom int)
+	Tab            func()
+	KeyFilter      func(key string, ch rune) bool
+	Highlight      func(input string) string
+	Incomplete     func(input string) bool
+	ContinuePrompt string
+	Click          func(line string, col int)
+	LinkDetectors  []LinkDetector
+	hover          *hoverLink
+	links          map[int]*lineLinks // by absolute line, until the output changes
+	linksGen       int
+	find           *finder
+	findBar        *js.Object
+	outputGen      int // incremented on every write
+	keymap         map[string]binding
+	killed         string
+	touch          bool // a touch device is in use
+	touchState     *touchState
+	velocity       float64 // momentum scrolling in rows per frame
+	toolbar        *js.Object
+	live           *js.Object // ARIA live region
+	liveText       string     // output not yet in the live region
+	text           *js.Object // output element of the text mode
+	textLines      textLines
+	textMode       bool
 }
 
 func New(parent *js.Object) (*Terminal, error) {
 	t := &Terminal{
-		parent:         parent,
-		dirty:          true,
-		selectedString: &bytes.Buffer{},
+		parent: parent,
+		dirty:  true,
+		screen: newScreen(scrollback),
+
+		ContinuePrompt: "> ",
+		LinkDetectors:  []LinkDetector{DetectURLs},
+		WordDelimiters: defaultWordDelimiters,
+		BracketedPaste: true,
 	}
+	t.bindDefaults()
+	t.SetTheme(DefaultTheme)
 	js.Global.Call("addEventListener", "resize", func() {
 		t.layout()
 	})
@@ -118,113 +140,118 @@ func New(parent *js.Object) (*Terminal, error) {
 
 	js.Global.Get("document").Call("addEventListener", "mousedown", func(ev *js.Object) bool {
 		t.mdown = true
-		t.mdownrow, t.mdowncol = t.getRowColForPixel(ev.Get("offsetX").Float(), ev.Get("offsetY").Float())
-		t.dirty = true
+		t.mdownX, t.mdownY = t.pointerPos(ev)
+		t.mdetail = ev.Get("detail").Int()
+		row, col := t.getRowColForPixel(t.mdownX, t.mdownY)
 		t.mtime = time.Now()
-		t.selStart = 0
-		t.selEnd = 0
-		t.dirty = true
+		t.selectStart(t.posForRowCol(row, col), t.mdetail, ev.Get("shiftKey").Bool())
+		if ev.Get("detail").Int() > 1 {
+			// keep the browser from selecting the page
+			ev.Call("preventDefault")
+		}
 		return true
 	})
 
 	js.Global.Get("document").Call("addEventListener", "mousemove", func(ev *js.Object) bool {
-		if t.mdown {
-			t.mmoverow, t.mmovecol = t.getRowColForPixel(ev.Get("offsetX").Float(), ev.Get("offsetY").Float())
-			t.dirty = true
-			if t.mdownrow == t.mmoverow {
-				rowStart := (t.mdownrow) * t.cols
-				if t.mdowncol == t.mmovecol {
-					t.selStart = 0
-					t.selEnd = 0
-				} else if t.mdowncol < t.mmovecol {
-					t.selStart = rowStart + t.mdowncol
-					t.selEnd = rowStart + t.mmovecol
-				} else {
-					t.selStart = rowStart + t.mmovecol
-					t.selEnd = rowStart + t.mdowncol
-				}
-			} else if t.mdownrow < t.mmoverow {
-				rowStart := (t.mdownrow) * t.cols
-				rowEnd := (t.mmoverow) * t.cols
-				t.selStart = rowStart + t.mdowncol
-				t.selEnd = rowEnd + t.mmovecol
+		x, y := t.pointerPos(ev)
+		if !t.mdown {
+			if t.renderer.element().Call("contains", ev.Get("target")).Bool() {
+				t.hoverAt(t.posForRowCol(t.getRowColForPixel(x, y)))
 			} else {
-				rowStart := (t.mmoverow) * t.cols
-				rowEnd := (t.mdownrow) * t.cols
-				t.selStart = rowStart + t.mmovecol
-				t.selEnd = rowEnd + t.mdowncol
+				t.setHover(nil)
 			}
+			return true
 		}
+		switch {
+		case y < pady:
+			t.dragDir = -1
+		case y > t.height/t.ratio-pady:
+			t.dragDir = +1
+		default:
+			t.dragDir = 0
+		}
+		row, col := t.getRowColForPixel(x, y)
+		t.selectTo(t.posForRowCol(row, col))
 		return true
 	})
 
-	// js.Global.Get("window").Call("addEventListener", "click", func(ev *js.Object) bool {
-	// 	row, col := t.getRowColForPixel(ev.Get("offsetX").Float(), ev.Get("offsetY").Float())
-	// 	pos := row*t.cols + col
-
-	// 	if ev.Get("detail").Int() >= 3 {
-	// 		//	println("triple click")
-	// 	} else if ev.Get("detail").Int() >= 2 {
-	// 		//	println("double click")
-	// 		println(pos)
-	// 	} else if ev.Get("detail").Int() >= 1 {
-
-	// 	}
-	// 	return true
-	// })
-
 	js.Global.Get("document").Call("addEventListener", "mouseup", func(ev *js.Object) bool {
 		if t.mdown {
 			t.mdown = false
+			t.dragDir = 0
 			t.dirty = true
+			if t.CopyOnSelect {
+				t.copySelection()
+			}
+			// a click doesn't select, and double clicks and drags
+			// that return to the same cell aren't clicks
+			x, y := t.pointerPos(ev)
+			if t.mdetail == 1 && t.selAnchor == t.selHead && abs(x-t.mdownX) <= clickSlop && abs(y-t.mdownY) <= clickSlop {
+				t.click(t.selAnchor)
+			}
 		}
 		return true
 	})
 
-	js.Global.Get("document").Call("addEventListener", "keydown", func(ev *js.Object) bool {
-		code := ev.Get("keyCode").Int()
-		switch code {
-		default:
+	// copy from the browser menu
+	js.Global.Get("document").Call("addEventListener", "copy", func(ev *js.Object) bool {
+		text := t.selectedText()
+		if text == "" || ev.Get("clipboardData") == js.Undefined {
 			return true
-		case 8, 46, 37, 38, 39, 40:
 		}
-		t.scrollToEndIfNotScrolling()
+		ev.Get("clipboardData").Call("setData", "text/plain", text)
+		ev.Call("preventDefault")
+		return false
+	})
 
-		if code == 8 || code == 46 {
-			ev.Call("preventDefault")
-			switch code {
-			case 8:
-				t.backspace()
-			case 46:
-				t.delete()
+	js.Global.Get("document").Call("addEventListener", "keydown", func(ev *js.Object) bool {
+		key := keyName(ev)
+		if t.find != nil {
+			if t.findKey(key) {
+				ev.Call("preventDefault")
+				return false
 			}
+			// keys like copy and zoom still work, but not the ones that
+			// edit the input behind the search bar
+			if b, ok := t.keymap[key]; ok && !b.edit {
+				ev.Call("preventDefault")
+				b.action()
+				return false
+			}
+			return true
+		}
+		if t.KeyFilter != nil && t.acceptInput && t.KeyFilter(key, 0) {
+			ev.Call("preventDefault")
 			return false
 		}
-		switch code {
-		case 37:
-			t.arrow(-1)
-		case 38:
-			if t.Up != nil {
-				t.Up()
-			}
-		case 39:
-			t.arrow(+1)
-		case 40:
-			if t.Down != nil {
-				t.Down()
-			}
+		if !t.pressKey(key) {
+			return true
 		}
-		return true
+		ev.Call("preventDefault")
+		return false
 	})
 	js.Global.Get("document").Call("addEventListener", "keypress", func(ev *js.Object) bool {
+		// keep the character out of the textarea, where it would be typed
+		// again by the input event
+		ev.Call("preventDefault")
+		if t.find != nil {
+			t.findChar(rune(ev.Get("keyCode").Int()))
+			return false
+		}
 		if !t.acceptInput {
 			return false
 		}
 		t.scrollToEndIfNotScrolling()
 		code := ev.Get("keyCode").Int()
+		if t.KeyFilter != nil && t.KeyFilter("", rune(code)) {
+			return false
+		}
 		t.appendChar(rune(code), true)
 		return true
 	})
+	t.initTouch()
+	t.initA11y()
+	t.SetRenderer(nil)
 
 	var raf string
 	for _, s := range []string{"requestAnimationFrame", "webkitRequestAnimationFrame", "mozRequestAnimationFrame"} {
@@ -248,6 +275,25 @@ func New(parent *js.Object) (*Terminal, error) {
 	return t, nil
 }
 
+// click opens the link at a position, or calls the Click hook with the
+// text of the line and the byte offset of the position in it.
+func (t *Terminal) click(pos textPos) {
+	if h := t.linkAt(pos); h != nil {
+		h.link.Open()
+		return
+	}
+	i := pos.line - t.screen.dropped
+	if t.Click == nil || i < 0 || i >= t.screen.lines.len() {
+		return
+	}
+	text, offsets := cellOffsets(t.displayLine(i))
+	col := len(text)
+	if pos.col < len(offsets) {
+		col = offsets[pos.col]
+	}
+	t.Click(text, col)
+}
+
 func (t *Terminal) getRowColForPixel(x, y float64) (row, col int) {
 	col = int((x - padx) / (float64(t.cols) * t.charWidth) * float64(t.cols))
 	if col < 0 {
@@ -267,9 +313,16 @@ func (t *Terminal) getRowColForPixel(x, y float64) (row, col int) {
 
 func (t *Terminal) appendChar(code rune, fromEvent bool) {
 	t.dirty = true
+	if !fromEvent && code == '\r' {
+		return
+	}
 	if (fromEvent && code == 13) || (!fromEvent && code == 10) {
 		input := t.input
-		t.WriteString(t.prompt + t.input + "\n")
+		if t.Incomplete != nil && t.Incomplete(input) {
+			t.newline()
+			return
+		}
+		t.WriteString(t.echoInput() + "\n")
 		if input != "" {
 			t.input = ""
 			t.cursorIdx = 0
@@ -277,11 +330,24 @@ func (t *Terminal) appendChar(code rune, fromEvent bool) {
 			if t.Input != nil {
 				t.Input(input)
 			}
+			t.pastedLines = false
 		}
 		return
 	}
-	t.input = t.input[:t.cursorIdx] + string(rune(code)) + t.input[t.cursorIdx:]
+	ch := string(rune(code))
+	t.input = t.input[:t.cursorIdx] + ch + t.input[t.cursorIdx:]
+	t.cursorIdx += len(ch)
+}
+
+// newline inserts a newline into the input, which continues on the next
+// row.
+func (t *Terminal) newline() {
+	if !t.acceptInput {
+		return
+	}
+	t.input = t.input[:t.cursorIdx] + "\n" + t.input[t.cursorIdx:]
 	t.cursorIdx++
+	t.dirty = true
 }
 
 func (t *Terminal) appendStr(s string) {
@@ -302,27 +368,35 @@ func (t *Terminal) scrollToEndIfNotScrolling() {
 	}
 }
 
+// backspace removes the character before the cursor. The cursor index is
+// a byte offset and a character may be several runes long.
 func (t *Terminal) backspace() {
-	if t.cursorIdx > 0 {
-		t.cursorIdx--
-		t.input = t.input[:t.cursorIdx] + t.input[t.cursorIdx+1:]
+	if t.acceptInput && t.cursorIdx > 0 {
+		n := lastGraphemeLen(t.input[:t.cursorIdx])
+		t.cursorIdx -= n
+		t.input = t.input[:t.cursorIdx] + t.input[t.cursorIdx+n:]
 		t.dirty = true
 	}
 }
 
 func (t *Terminal) delete() {
-	if t.cursorIdx < len(t.input) {
-		t.input = t.input[:t.cursorIdx] + t.input[t.cursorIdx+1:]
+	if t.acceptInput && t.cursorIdx < len(t.input) {
+		n := graphemeLen(t.input[t.cursorIdx:])
+		t.input = t.input[:t.cursorIdx] + t.input[t.cursorIdx+n:]
 		t.dirty = true
 	}
 }
 
+// arrow moves the cursor by delta characters.
 func (t *Terminal) arrow(delta int) {
-	t.cursorIdx += delta
-	if t.cursorIdx < 0 {
-		t.cursorIdx = 0
-	} else if t.cursorIdx > len(t.input) {
-		t.cursorIdx = len(t.input)
+	if !t.acceptInput {
+		return
+	}
+	for ; delta < 0 && t.cursorIdx > 0; delta++ {
+		t.cursorIdx -= lastGraphemeLen(t.input[:t.cursorIdx])
+	}
+	for ; delta > 0 && t.cursorIdx < len(t.input); delta-- {
+		t.cursorIdx += graphemeLen(t.input[t.cursorIdx:])
 	}
 	t.dirty = true
 }
@@ -341,59 +415,86 @@ func (t *Terminal) layout() {
 	}
 	width := t.parent.Get("offsetWidth").Float() * ratio
 	height := t.parent.Get("offsetHeight").Float() * ratio
-	if t.canvas != nil && t.width == width && t.height == height && t.ratio == ratio {
+	if t.toolbar != nil {
+		height -= t.toolbar.Get("offsetHeight").Float() * ratio
+	}
+	if t.renderer == nil || (t.width == width && t.height == height && t.ratio == ratio) {
 		return
 	}
 	t.width, t.height, t.ratio = width, height, ratio
-	if t.canvas != nil {
-		t.parent.Call("removeChild", t.canvas)
-	}
 
 	if t.textarea == nil {
 		t.textarea = js.Global.Get("document").Call("createElement", "textarea")
 		t.parent.Call("appendChild", t.textarea)
 		t.textarea.Get("style").Set("position", "absolute")
 		t.textarea.Get("style").Set("opacity", 0)
+		t.labelInput()
 		t.textarea.Call("addEventListener", "paste", func(ev *js.Object) bool {
+			if data := ev.Get("clipboardData"); data != js.Undefined && data != nil {
+				ev.Call("preventDefault")
+				t.pasteEvent(data.Call("getData", "text").String())
+				return false
+			}
+			// read the pasted text from the textarea on the next frame
+			t.textarea.Set("value", "")
 			t.pasted = true
 			return true
 		})
+		t.textarea.Call("addEventListener", "input", t.softInput)
+		t.textarea.Call("addEventListener", "compositionend", func(ev *js.Object) {
+			if t.acceptInput {
+				t.softText(ev.Get("data"))
+			}
+			t.textarea.Set("value", "")
+		})
 	}
 
-	t.canvas = js.Global.Get("document").Call("createElement", "canvas")
-	t.ctx = t.canvas.Call("getContext", "2d")
-	t.canvas.Set("width", t.width)
-	t.canvas.Set("height", t.height)
-	t.canvas.Get("style").Set("width", ftoa(t.width/t.ratio)+"px")
-	t.canvas.Get("style").Set("height", ftoa(t.height/t.ratio)+"px")
-	t.canvas.Get("style").Set("position", "absolute")
-	t.canvas.Get("style").Set("backgroundColor", backgroundColor)
-	t.parent.Call("appendChild", t.canvas)
+	// keep the top visible line in view when the text is reflowed
+	var anchor textPos
+	anchored := t.cols > 0 && t.scrollY > 0
+	if anchored {
+		anchor = t.posForRowCol(t.rowOffset, 0)
+	}
 
-	t.ctx.Set("font", itoa(int(fontSize*t.ratio))+"px "+font)
-	t.charWidth = t.ctx.Call("measureText", "01234567890123456789").Get("width").Float() / 20 / t.ratio
-	t.charHeight = fontSize + linepad
+	t.charWidth, t.charHeight = t.renderer.resize(t.width/t.ratio, t.height/t.ratio, t.ratio, t.theme.FontFamily, t.fontSize())
 	t.rows = int((t.height - (pady * 2 * t.ratio)) / (t.charHeight * t.ratio))
 	t.cols = int((t.width - (padx * 2 * t.ratio)) / (t.charWidth * t.ratio))
-	t.resetFontStyle()
-
+	// a container too small for a single cell still gets one, since rows
+	// and columns are divided by
+	if t.rows < 1 {
+		t.rows = 1
+	}
+	if t.cols < 1 {
+		t.cols = 1
+	}
+	t.screen.resize(t.rows)
+	if anchored {
+		if t.acceptInput {
+			t.buildInputLine()
+		}
+		t.scrollY = float64(t.totalRows() - t.rows - t.rowForPos(anchor))
+	}
 	t.loop(t.timestamp)
 }
 
 func (t *Terminal) loop(timestamp Duration) {
-	if t.textarea != nil {
+	if t.textarea != nil && !t.touch && !t.textMode {
+		// on touch devices focus would open the soft keyboard, so it's
+		// only focused on a tap, and in text mode the reader moves it
 		t.textarea.Call("focus")
 	}
+	t.momentum()
+	t.flushLive()
 	if t.pasted {
 		t.pasted = false
-		if t.acceptInput {
-			t.appendStr(t.textarea.Get("value").String())
-		}
+		t.paste(t.textarea.Get("value").String())
+		t.textarea.Set("value", "")
 	}
 	if timestamp == 0 || t.timestamp == 0 {
 		t.timestamp = timestamp
 		return
 	}
+	t.dragScroll()
 	t.timestamp = timestamp
 	if !t.dirty {
 		return
@@ -401,7 +502,6 @@ func (t *Terminal) loop(timestamp Duration) {
 	defer func() {
 		t.dirty = false
 	}()
-	t.ctx.Call("clearRect", 0, 0, t.width, t.height)
 	t.draw()
 }
 
@@ -409,19 +509,33 @@ func (t *Terminal) ClearInput() {
 	t.prompt = ""
 	t.acceptInput = false
 	t.input = ""
+	t.pastedLines = false
 	t.dirty = true
 }
 
 func (t *Terminal) SetInput(input string) {
 	t.input = input
 	t.cursorIdx = len(t.input)
+	t.pastedLines = false
 	t.dirty = true
 }
 
+// PastedLines reports whether the input has several lines from a bracketed
+// paste, rather than lines typed with Shift-Enter or continued because the
+// input was incomplete. It's meant to be called from the Input hook.
+func (t *Terminal) PastedLines() bool {
+	return t.pastedLines
+}
+
 func (t *Terminal) GetInput() string {
 	return t.input
 }
 
+// AcceptingInput reports whether the terminal is showing a prompt.
+func (t *Terminal) AcceptingInput() bool {
+	return t.acceptInput
+}
+
 func (t *Terminal) Prompt(prompt string) {
 	t.prompt = prompt
 	t.acceptInput = true
@@ -429,249 +543,136 @@ func (t *Terminal) Prompt(prompt string) {
 }
 
 func (t *Terminal) WriteString(s string) {
-	t.buffer += s
+	t.screen.write(s)
+	t.outputGen++
+	t.announce(s)
 	t.dirty = true
 }
 
-func (t *Terminal) drawChar(row, col int, ch rune) {
-	if row < 0 || row >= t.rows || col < 0 || col >= t.cols {
-		return
-	}
-	x, y := (padx*t.ratio + float64(col)*t.charWidth*t.ratio), (pady+float64(row+1)*t.charHeight-linepad)*t.ratio
-	t.ctx.Call("fillText", string(ch), x, y)
+// Clear removes all output from the terminal.
+func (t *Terminal) Clear() {
+	t.screen.reset()
+	t.outputGen++
+	t.clearSelection()
+	t.scrollToEnd()
 }
 
-func (t *Terminal) drawCursor(row, col int, solid bool) {
-	if row < 0 || row >= t.rows || col < 0 || col >= t.cols {
-		return
-	}
-	x, y := (padx*t.ratio + float64(col)*t.charWidth*t.ratio), (pady+float64(row+1)*t.charHeight)*t.ratio
-	if solid || js.Global.Get("document").Call("hasFocus").Bool() {
-		t.ctx.Call("fillRect", x, y-(t.charHeight*t.ratio), (t.charWidth+0.5)*t.ratio, (t.charHeight+0.5)*t.ratio)
-	} else {
-		t.ctx.Set("lineWidth", 1*t.ratio)
-		t.ctx.Call("strokeRect", x, y-(t.charHeight*t.ratio), (t.charWidth+0.5)*t.ratio, (t.charHeight+0.5)*t.ratio)
-	}
-}
-
-func (t *Terminal) setColor(color string) {
-	t.color = color
-	t.setFontStyle()
-}
-func (t *Terminal) setBright(bright bool) {
-	t.bright = bright
-	t.setFontStyle()
-}
-func (t *Terminal) setFontStyle() {
-	t.ctx.Call("restore")
-	t.ctx.Call("save")
-	var s string
-	if t.bright {
-		s += "Bold "
-	}
-	s += itoa(int(fontSize*t.ratio)) + "px "
-	s += font
-	t.ctx.Set("font", s)
-	t.ctx.Set("fillStyle", t.color)
-	t.ctx.Set("strokeStyle", t.color)
-}
-
-func (t *Terminal) resetFontStyle() {
-	t.color = baseColor
-	t.bright = false
-	t.setFontStyle()
+// SetScrollback sets the number of lines kept above the visible rows.
+func (t *Terminal) SetScrollback(lines int) {
+	t.screen.setScrollback(lines)
+	t.dirty = true
 }
 
-func (t *Terminal) handleESC(esc string) {
-	switch esc {
-	default:
-		//println(esc)
-	case clear:
-		t.resetFontStyle()
-	case red:
-		t.setColor("red")
-	case bright:
-		t.setBright(true)
-	case dim:
-		t.setBright(false)
-	case green:
-		t.setColor("green")
-	case yellow:
-		t.setColor("yellow")
-	case blue:
-		t.setColor("blue")
-	case magenta:
-		t.setColor("magenta")
-	case cyan:
-		t.setColor("cyan")
-	case white:
-		t.setColor("white")
-	case dgray:
-		t.setColor("#666")
-	}
-}
-func (t *Terminal) drawBuffer(s string, charIdx int, x, y int, esc bool, escs string, cursorIdx int, blit bool) (int, int, int, bool, string) {
-	i := 0
-	cursor := false
-	for _, ch := range s {
-		if esc {
-			if escs == "" && ch != '[' && ch != 0x1B {
-				esc = false
-			} else {
-				if ch != 0x1B {
-					escs += string(ch)
-				}
-				if ch == 'm' {
-					esc = false
-				}
-				continue
-			}
-		} else {
-			if ch == 0x1B {
-				if escs != "" {
-					if blit {
-						t.handleESC(escs)
-					}
-				}
-				esc = true
-				escs = ""
+// Text returns the terminal output with all escape sequences removed.
+func (t *Terminal) Text() string {
+	return t.screen.text()
+}
+
+// buildFrame builds the visible part of the screen, where top is the first
+// visible row.
+func (t *Terminal) buildFrame(top int) *frame {
+	f := &frame{
+		cells:   make([][]frameCell, t.rows),
+		theme:   &t.theme,
+		palette: &t.palette,
+		focused: js.Global.Get("document").Call("hasFocus").Bool(),
+	}
+	for y := range f.cells {
+		f.cells[y] = make([]frameCell, t.cols)
+	}
+	scr := t.screen
+	start, end := t.selection()
+	first := t.lineAtRow(top)
+	row := t.rowsBefore(first)
+	for i := first; i >= 0 && i < scr.lines.len() && row < top+t.rows; i++ {
+		n := t.displayRows(i)
+		line := t.displayLine(i)
+		abs := scr.dropped + i
+		cursor := -1
+		if t.acceptInput && i == scr.row {
+			cursor = t.inputCursor
+		}
+		// past the end of the line there's the line break, which can be
+		// selected, and the cursor
+		last := len(line)
+		if cursor > last {
+			last = cursor
+		}
+		w := wrapper{cols: t.cols}
+		for j := 0; j <= last; j++ {
+			r, x := w.place(isWideHead(line, j))
+			y := row + r - top
+			if y < 0 {
 				continue
+			} else if y >= t.rows {
+				break
 			}
-		}
-		if escs != "" {
-			if blit {
-				t.handleESC(escs)
+			var c frameCell
+			if j < len(line) {
+				c.cell = line[j]
 			}
-			escs = ""
-		}
-		if ch == '\r' {
-			x = 0
-			if !blit {
-				pos := (y+(t.rowOffset))*t.cols + x
-				if pos >= t.selStart && pos < t.selEnd {
-					t.selectedString.WriteRune(ch)
-				}
+			pos := textPos{abs, j}
+			if j == cursor {
+				c.mark |= markCursor
 			}
-		} else if ch == '\n' {
-			x = 0
-			y++
-			if !blit {
-				pos := (y+(t.rowOffset))*t.cols + x
-				if pos >= t.selStart && pos < t.selEnd {
-					t.selectedString.WriteRune(ch)
-				}
+			if j <= len(line) && !pos.less(start) && pos.less(end) {
+				c.mark |= markSelected
 			}
-		} else {
-			pos := (y+(t.rowOffset))*t.cols + x
-			if i == cursorIdx {
-				if blit {
-					t.drawCursor(y, x, false)
-					t.setColor("black")
-					t.drawChar(y, x, ch)
-					t.setColor(baseColor)
-				}
-				cursor = true
-			} else {
-				if pos >= t.selStart && pos < t.selEnd {
-					if blit {
-						t.setColor(selectionColor)
-						t.drawCursor(y, x, true)
-						t.setColor("black")
-						t.drawChar(y, x, ch)
-						t.setColor(baseColor)
-					} else {
-						t.selectedString.WriteRune(ch)
-					}
-				} else {
-					if blit {
-						t.drawChar(y, x, ch)
-					}
+			if j < len(line) {
+				c.mark |= t.matchAt(pos)
+				if t.isHovered(pos) {
+					c.mark |= markLink
 				}
 			}
-			x++
-			if x >= t.cols {
-				x = 0
-				y++
-			}
-			i++
-			charIdx++
-		}
-	}
-	if !cursor && cursorIdx != -1 {
-		if blit {
-			t.drawCursor(y, x, false)
-		}
-	}
-	return x, y, charIdx, esc, escs
-}
-
-func (t *Terminal) drawSelectionBlocks() {
-	for y := 0 + t.rowOffset; y < t.rows+t.rowOffset; y++ {
-		for x := 0; x < t.cols; x++ {
-			pos := y*t.cols + x
-			if pos >= t.selStart && pos < t.selEnd {
-				t.setColor(selectionColor)
-				t.drawCursor(y+t.rowOffset*-1, x, true)
-				t.setColor(baseColor)
+			if j >= len(line) && c.mark == 0 {
+				continue
 			}
+			f.cells[y][x] = c
 		}
+		row += n
 	}
+	return f
 }
 
-func (t *Terminal) calcDrawBuffers(x, y int, esc bool, escs string, blit bool) (int, int, bool, string) {
-	charIdx := 0
-	x, y, charIdx, esc, escs = t.drawBuffer(t.buffer, charIdx, x, y, esc, escs, -1, blit)
+func (t *Terminal) draw() {
 	if t.acceptInput {
-		x, y, charIdx, esc, escs = t.drawBuffer(t.prompt, charIdx, x, y, esc, escs, -1, blit)
-		x, y, charIdx, esc, escs = t.drawBuffer(t.input, charIdx, x, y, esc, escs, t.cursorIdx, blit)
+		t.buildInputLine()
 	}
-	return x, y, esc, escs
-}
-
-func (t *Terminal) draw() {
-	t.selectedString.Reset()
-
-	// predraw - do not blit
-	x, y, esc, escs := t.calcDrawBuffers(0, 0, true, "", false)
+	if t.find != nil && t.find.gen != t.outputGen {
+		t.searchScreen()
+	}
+	t.syncInput()
+	t.drawFind()
+	if t.textMode {
+		t.drawText()
+		return
+	}
+	last := t.totalRows() - 1
 
 	minScrollY := 0.0
-	maxScrollY := float64(y - t.rows + 1)
+	maxScrollY := float64(last - t.rows + 1)
 	if maxScrollY < 0 {
 		maxScrollY = 0
 	}
 
-	t.ctx.Call("save")
-	defer t.ctx.Call("restore")
-
-	if y >= t.rows {
-		y = (t.rows - y - 1)
-	} else {
-		y = 0
-	}
-
 	if t.scrollY < minScrollY {
 		t.scrollY = minScrollY
 	} else if t.scrollY > maxScrollY {
 		t.scrollY = maxScrollY
 	}
 
-	y += int(t.scrollY)
-	t.rowOffset = 0 - y
+	top := last - t.rows + 1 - int(t.scrollY)
+	if top < 0 {
+		top = 0
+	}
+	t.rowOffset = top
 	t.maxRowOffset = int(maxScrollY)
 
-	// real drawing goes here
-	t.drawSelectionBlocks()
-	x, esc, escs = 0, true, ""
-	x, y, esc, escs = t.calcDrawBuffers(x, y, esc, escs, true)
-
-	if dbgBorder {
-		t.ctx.Call("restore")
-		t.ctx.Set("strokeStyle", baseColor)
-		t.ctx.Call("strokeRect", padx*t.ratio, pady*t.ratio, (float64(t.cols) * t.charWidth * t.ratio), (float64(t.rows)*t.charHeight)*t.ratio)
-		t.ctx.Call("save")
-	}
-	//t.textarea.Get("style").Set("display", "none")
-	t.textarea.Set("value", t.selectedString.String())
-	t.textarea.Call("focus")
-	t.textarea.Call("select")
+	f := t.buildFrame(top)
+	start := js.Global.Get("performance").Call("now").Float()
+	t.renderer.draw(f)
+	t.measureFrame(js.Global.Get("performance").Call("now").Float() - start)
+	if !t.touch && !t.textMode {
+		t.textarea.Call("focus")
+	}
 }
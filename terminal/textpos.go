@@ -0,0 +1,217 @@
+package terminal
+
+import (
+	"sort"
+	"strings"
+)
+
+// textPos is a position in the terminal output. The line is an absolute line
+// number, which stays the same when lines are dropped from the scrollback,
+// and col is the offset into the logical line. Because positions don't
+// depend on how lines are wrapped, they stay anchored to the same text when
+// the terminal is resized.
+type textPos struct {
+	line, col int
+}
+
+func (p textPos) less(q textPos) bool {
+	return p.line < q.line || (p.line == q.line && p.col < q.col)
+}
+
+// displayLine returns line i of the screen as it is drawn. While accepting
+// input, the prompt and input replace the cursor line from the cursor on.
+func (t *Terminal) displayLine(i int) []cell {
+	if t.acceptInput && i == t.screen.row {
+		return t.inputLine
+	}
+	return t.screen.lines.at(i)
+}
+
+// displayRows returns the number of rows used by display line i.
+func (t *Terminal) displayRows(i int) int {
+	line := t.displayLine(i)
+	if t.acceptInput && i == t.screen.row && t.inputCursor >= len(line) {
+		// room for the cursor after the input
+		return cellRow(line, t.inputCursor, t.cols) + 1
+	}
+	return lineRows(line, t.cols)
+}
+
+// rowsBefore returns the number of rows used by the display lines before
+// line i.
+func (t *Terminal) rowsBefore(i int) int {
+	scr := t.screen
+	rows := scr.lines.rowsBefore(i, t.cols)
+	if t.acceptInput && scr.row < i {
+		// the ring counted the line that the input replaces
+		rows += t.displayRows(scr.row) - lineRows(scr.lines.at(scr.row), t.cols)
+	}
+	return rows
+}
+
+// lineAtRow returns the display line at an absolute row, or the last line
+// past the end.
+func (t *Terminal) lineAtRow(row int) int {
+	n := t.screen.lines.len()
+	i := sort.Search(n, func(i int) bool {
+		return t.rowsBefore(i+1) > row
+	})
+	if i == n {
+		i = n - 1
+	}
+	return i
+}
+
+// totalRows returns the number of rows used by all display lines.
+func (t *Terminal) totalRows() int {
+	return t.rowsBefore(t.screen.lines.len())
+}
+
+// buildInputLine lays out the prompt and input at the screen cursor. The
+// input is passed through the Highlight hook, which may add SGR sequences.
+func (t *Terminal) buildInputLine() {
+	scr := t.screen
+	line := scr.lines.at(scr.row)
+	if len(line) > scr.col {
+		line = line[:scr.col]
+	}
+	t.inputLine = append(t.inputLine[:0], line...)
+	for len(t.inputLine) < scr.col {
+		t.inputLine = append(t.inputLine, cell{})
+	}
+	t.inputLine = appendStyled(t.inputLine, t.prompt)
+	input := t.input
+	if t.Highlight != nil {
+		input = t.Highlight(input)
+	}
+	// newlines in the input continue on the next row after the
+	// continuation prompt
+	cursor := graphemeCount(t.input[:t.cursorIdx])
+	t.inputCursor = -1
+	i := 0
+	for _, c := range appendStyled(nil, input) {
+		if c.ch != wideTail {
+			if i == cursor {
+				t.inputCursor = len(t.inputLine)
+			}
+			i++
+		}
+		if c.ch != '\n' {
+			t.inputLine = append(t.inputLine, c)
+			continue
+		}
+		w := wrapper{cols: t.cols}
+		for j := range t.inputLine {
+			w.place(isWideHead(t.inputLine, j))
+		}
+		for ; t.cols > 0 && w.col%t.cols != 0; w.col++ {
+			t.inputLine = append(t.inputLine, cell{})
+		}
+		t.inputLine = appendStyled(t.inputLine, t.ContinuePrompt)
+	}
+	if t.inputCursor == -1 {
+		t.inputCursor = len(t.inputLine)
+	}
+}
+
+// echoInput returns the prompt and input as they are written to the output
+// when the input is submitted.
+func (t *Terminal) echoInput() string {
+	return t.prompt + strings.Replace(t.input, "\n", "\n"+t.ContinuePrompt, -1)
+}
+
+// appendStyled appends the characters of s to line, one cell for each
+// user-perceived character and two for wide ones. SGR sequences in s set
+// the rendition and other escape sequences are ignored.
+func appendStyled(line []cell, s string) []cell {
+	var a attr
+	var esc bool
+	var escs []byte
+	for _, ch := range s {
+		if esc {
+			escs = append(escs, byte(ch))
+			if len(escs) > 1 && ch >= 0x40 && ch <= 0x7E {
+				esc = false
+				if ch == 'm' && escs[0] == '[' {
+					a.applySGR(string(escs[1 : len(escs)-1]))
+				}
+			}
+			continue
+		}
+		if ch == 0x1B {
+			esc, escs = true, escs[:0]
+			continue
+		}
+		if head, ok := joinsCell(line, len(line), ch); ok {
+			line, _ = joinCell(line, head, len(line), ch)
+			continue
+		}
+		line = append(line, cell{ch: ch, attr: a})
+		if runeWidth(ch) == 2 {
+			line = append(line, cell{ch: wideTail, attr: a})
+		}
+	}
+	return line
+}
+
+// posForRowCol returns the text position for an absolute row and column.
+func (t *Terminal) posForRowCol(row, col int) textPos {
+	scr := t.screen
+	if scr.lines.len() == 0 {
+		return textPos{scr.dropped, 0}
+	}
+	i := t.lineAtRow(row)
+	line := t.displayLine(i)
+	// the first cell at or after the row and column
+	row -= t.rowsBefore(i)
+	c := len(line)
+	w := wrapper{cols: t.cols}
+	for j := range line {
+		r, x := w.place(isWideHead(line, j))
+		if r > row || r == row && x >= col {
+			c = j
+			break
+		}
+	}
+	if c < len(line) && c > 0 && line[c].ch == wideTail {
+		c--
+	}
+	return textPos{scr.dropped + i, c}
+}
+
+// rowForPos returns the absolute row of a text position.
+func (t *Terminal) rowForPos(pos textPos) int {
+	scr := t.screen
+	i := pos.line - scr.dropped
+	if i < 0 {
+		return 0
+	}
+	var line []cell
+	if i < scr.lines.len() {
+		line = t.displayLine(i)
+	}
+	return t.rowsBefore(i) + cellRow(line, pos.col, t.cols)
+}
+
+// cellText returns the characters of a line.
+func cellText(line []cell) string {
+	text, _ := cellOffsets(line)
+	return text
+}
+
+// cellOffsets returns the characters of a line and the byte offset of each
+// cell in them. The second cell of a wide character has the offset of the
+// character, so the offsets never decrease.
+func cellOffsets(line []cell) (string, []int) {
+	var buf []byte
+	offsets := make([]int, len(line))
+	for i, c := range line {
+		if c.ch == wideTail && i > 0 {
+			offsets[i] = offsets[i-1]
+			continue
+		}
+		offsets[i] = len(buf)
+		buf = append(buf, c.text()...)
+	}
+	return string(buf), offsets
+}
@@ -0,0 +1,145 @@
+package terminal
+
+import "sort"
+
+// Theme sets the colors and font of the terminal. Colors are CSS colors and
+// Palette holds the 16 standard and bright ANSI colors.
+type Theme struct {
+	Foreground string
+	Background string
+	Cursor     string
+	Selection  string
+	Palette    [16]string
+	FontFamily string
+	FontSize   int
+}
+
+// DefaultTheme is the theme of a new terminal.
+var DefaultTheme = Themes["dark"]
+
+// Themes are the built-in themes.
+var Themes = map[string]Theme{
+	"dark": {
+		Foreground: "#bbb",
+		Background: "#000",
+		Cursor:     "#bbb",
+		Selection:  "#7be",
+		Palette: [16]string{
+			"#000", "#c33", "#3c3", "#cc3", "#36e", "#c3c", "#3cc", "#ccc",
+			"#666", "#f55", "#5f5", "#ff5", "#55f", "#f5f", "#5ff", "#fff",
+		},
+		FontFamily: defaultFont,
+		FontSize:   12,
+	},
+	"light": {
+		Foreground: "#333",
+		Background: "#fff",
+		Cursor:     "#333",
+		Selection:  "#7be",
+		Palette: [16]string{
+			"#000", "#b22", "#171", "#862", "#22a", "#928", "#178", "#bbb",
+			"#555", "#d33", "#292", "#a72", "#33c", "#a3a", "#299", "#ddd",
+		},
+		FontFamily: defaultFont,
+		FontSize:   12,
+	},
+	"high-contrast": {
+		Foreground: "#fff",
+		Background: "#000",
+		Cursor:     "#ff0",
+		Selection:  "#ff0",
+		Palette: [16]string{
+			"#000", "#f33", "#3f3", "#ff3", "#59f", "#f3f", "#3ff", "#fff",
+			"#888", "#f66", "#6f6", "#ff6", "#8bf", "#f6f", "#6ff", "#fff",
+		},
+		FontFamily: defaultFont,
+		FontSize:   14,
+	},
+	"solarized": {
+		Foreground: "#839496",
+		Background: "#002b36",
+		Cursor:     "#93a1a1",
+		Selection:  "#268bd2",
+		Palette: [16]string{
+			"#073642", "#dc322f", "#859900", "#b58900", "#268bd2", "#d33682", "#2aa198", "#eee8d5",
+			"#586e75", "#cb4b16", "#93a1a1", "#657b83", "#839496", "#6c71c4", "#93a1a1", "#fdf6e3",
+		},
+		FontFamily: defaultFont,
+		FontSize:   12,
+	},
+}
+
+// ThemeNames returns the names of the built-in themes.
+func ThemeNames() []string {
+	var names []string
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetTheme changes the colors and font of the terminal.
+func (t *Terminal) SetTheme(theme Theme) {
+	relayout := theme.FontFamily != t.theme.FontFamily || theme.FontSize != t.theme.FontSize
+	t.theme = theme
+	t.palette = palette
+	copy(t.palette[:16], theme.Palette[:])
+	t.parent.Get("style").Set("background", theme.Background)
+	t.styleToolbar()
+	if t.renderer != nil && relayout {
+		t.relayout()
+	}
+	t.dirty = true
+}
+
+// Font size limits for zooming.
+const (
+	minFontSize = 6
+	maxFontSize = 40
+)
+
+// fontSize returns the theme font size adjusted by the zoom.
+func (t *Terminal) fontSize() int {
+	size := t.theme.FontSize + t.zoom
+	if size < minFontSize {
+		return minFontSize
+	} else if size > maxFontSize {
+		return maxFontSize
+	}
+	return size
+}
+
+// SetZoom sets the number of points added to the theme font size.
+func (t *Terminal) SetZoom(zoom int) {
+	if zoom == t.zoom {
+		return
+	}
+	t.zoom = zoom
+	if t.renderer != nil {
+		t.relayout()
+	}
+	t.dirty = true
+}
+
+// zoomBy changes the zoom from a key binding, where 0 resets it.
+func (t *Terminal) zoomBy(delta int) {
+	zoom := t.zoom + delta
+	if delta == 0 {
+		zoom = 0
+	}
+	if size := t.theme.FontSize + zoom; size < minFontSize || size > maxFontSize {
+		return
+	}
+	t.SetZoom(zoom)
+	if t.ZoomChanged != nil {
+		t.ZoomChanged(t.zoom)
+	}
+}
+
+// relayout lays out the terminal again after the font has changed. The
+// scroll position is kept by layout.
+func (t *Terminal) relayout() {
+	t.width = 0
+	t.layout()
+}
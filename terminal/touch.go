@@ -0,0 +1,242 @@
+package terminal
+
+import (
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+const (
+	longPress     = time.Millisecond * 500
+	tapSlop       = 10   // pixels a tap may move before it's a scroll
+	momentumDecay = 0.95 // velocity kept per frame after a fling
+)
+
+// touchState tracks a touch on the terminal.
+type touchState struct {
+	startX, startY float64
+	lastY          float64
+	lastTime       time.Time
+	moved          bool
+	selecting      bool // a long press started a selection
+	timer          *js.Object
+}
+
+// touchPoint returns the position of the first touch of an event relative
+// to the renderer element.
+func (t *Terminal) touchPoint(ev *js.Object) (x, y float64) {
+	return t.pointerPos(ev.Get("changedTouches").Index(0))
+}
+
+// initTouch adds the touch handlers, the soft keyboard handling and the
+// toolbar. The toolbar is only shown on devices with a coarse pointer.
+func (t *Terminal) initTouch() {
+	t.parent.Call("addEventListener", "touchstart", func(ev *js.Object) {
+		if t.renderer == nil || !t.renderer.element().Call("contains", ev.Get("target")).Bool() {
+			return
+		}
+		ev.Call("preventDefault")
+		t.touch = true
+		t.velocity = 0
+		x, y := t.touchPoint(ev)
+		ts := &touchState{startX: x, startY: y, lastY: y, lastTime: time.Now()}
+		ts.timer = js.Global.Call("setTimeout", func() {
+			ts.selecting = true
+			row, col := t.getRowColForPixel(x, y)
+			t.selectStart(t.posForRowCol(row, col), 2, false)
+			if js.Global.Get("navigator").Get("vibrate") != js.Undefined {
+				js.Global.Get("navigator").Call("vibrate", 20)
+			}
+		}, int(longPress/time.Millisecond))
+		t.touchState = ts
+	}, map[string]interface{}{"passive": false})
+
+	t.parent.Call("addEventListener", "touchmove", func(ev *js.Object) {
+		ts := t.touchState
+		if ts == nil {
+			return
+		}
+		ev.Call("preventDefault")
+		x, y := t.touchPoint(ev)
+		if !ts.moved && (abs(x-ts.startX) > tapSlop || abs(y-ts.startY) > tapSlop) {
+			ts.moved = true
+			js.Global.Call("clearTimeout", ts.timer)
+		}
+		if ts.selecting {
+			row, col := t.getRowColForPixel(x, y)
+			t.selectTo(t.posForRowCol(row, col))
+			return
+		}
+		if !ts.moved {
+			return
+		}
+		rows := (y - ts.lastY) / t.charHeight
+		t.scrollY += rows
+		now := time.Now()
+		if dt := now.Sub(ts.lastTime).Seconds(); dt > 0 {
+			// rows per frame at 60 frames per second
+			t.velocity = rows / dt / 60
+		}
+		ts.lastY, ts.lastTime = y, now
+		t.dirty = true
+	}, map[string]interface{}{"passive": false})
+
+	t.parent.Call("addEventListener", "touchend", func(ev *js.Object) {
+		ts := t.touchState
+		if ts == nil {
+			return
+		}
+		t.touchState = nil
+		js.Global.Call("clearTimeout", ts.timer)
+		switch {
+		case ts.selecting:
+			if t.CopyOnSelect {
+				t.copySelection()
+			}
+		case ts.moved:
+			if time.Since(ts.lastTime) > time.Millisecond*100 {
+				// the finger stopped before lifting
+				t.velocity = 0
+			}
+		default:
+			// a tap opens the soft keyboard, which needs a user gesture
+			t.clearSelection()
+			t.textarea.Call("focus")
+			row, col := t.getRowColForPixel(ts.startX, ts.startY)
+			t.click(t.posForRowCol(row, col))
+		}
+	})
+
+	// shrink the terminal to the visible viewport when the soft keyboard
+	// opens, and keep the prompt in view
+	if vv := js.Global.Get("visualViewport"); vv != js.Undefined && vv != nil {
+		vv.Call("addEventListener", "resize", func() {
+			if !t.touch {
+				return
+			}
+			t.parent.Get("style").Set("height", ftoa(vv.Get("height").Float())+"px")
+			t.layout()
+			t.scrollToEnd()
+		})
+	}
+
+	if js.Global.Call("matchMedia", "(pointer: coarse)").Get("matches").Bool() {
+		t.touch = true
+		t.addToolbar()
+	}
+}
+
+// momentum continues scrolling after a fling.
+func (t *Terminal) momentum() {
+	if t.velocity == 0 || t.touchState != nil {
+		return
+	}
+	t.scrollY += t.velocity
+	t.velocity *= momentumDecay
+	if abs(t.velocity) < 0.05 {
+		t.velocity = 0
+	}
+	t.dirty = true
+}
+
+// softInput handles the textarea input event, which is how soft keyboards
+// type. Hardware keys are handled on keypress and never reach the textarea.
+func (t *Terminal) softInput(ev *js.Object) {
+	if !t.acceptInput {
+		t.textarea.Set("value", "")
+		return
+	}
+	switch ev.Get("inputType").String() {
+	case "insertCompositionText":
+		// typed when the composition ends
+		return
+	case "insertText", "insertReplacementText":
+		t.softText(ev.Get("data"))
+	case "insertLineBreak", "insertParagraph":
+		t.appendChar(13, true)
+	case "deleteContentBackward":
+		t.backspace()
+	case "deleteContentForward":
+		t.delete()
+	}
+	t.textarea.Set("value", "")
+	t.scrollToEnd()
+}
+
+// softText types the text of a soft keyboard or input method.
+func (t *Terminal) softText(data *js.Object) {
+	if data == nil || data == js.Undefined {
+		return
+	}
+	for _, ch := range data.String() {
+		t.appendChar(ch, true)
+	}
+}
+
+// toolbarKeys are the buttons of the touch toolbar and the keys they press.
+var toolbarKeys = []struct{ label, key string }{
+	{"Tab", "Tab"},
+	{"◀", "Left"},
+	{"▶", "Right"},
+	{"▲", "Up"},
+	{"▼", "Down"},
+	{"^C", "Ctrl-C"},
+	{"Paste", "Ctrl-V"},
+}
+
+// addToolbar adds the on-screen toolbar below the terminal.
+func (t *Terminal) addToolbar() {
+	doc := js.Global.Get("document")
+	bar := doc.Call("createElement", "div")
+	style := bar.Get("style")
+	style.Set("position", "absolute")
+	style.Set("left", "0")
+	style.Set("right", "0")
+	style.Set("bottom", "0")
+	style.Set("display", "flex")
+	style.Set("height", "40px")
+	for _, tk := range toolbarKeys {
+		key := tk.key
+		if _, ok := t.keymap[key]; !ok {
+			// such as paste without the Clipboard API
+			continue
+		}
+		btn := doc.Call("createElement", "button")
+		btn.Set("textContent", tk.label)
+		bs := btn.Get("style")
+		bs.Set("flex", "1")
+		bs.Set("font", "14px sans-serif")
+		bs.Set("border", "none")
+		btn.Call("addEventListener", "touchstart", func(ev *js.Object) {
+			// keep the focus, and the soft keyboard, on the textarea
+			ev.Call("preventDefault")
+			t.pressKey(key)
+		}, map[string]interface{}{"passive": false})
+		bar.Call("appendChild", btn)
+	}
+	t.parent.Call("appendChild", bar)
+	t.toolbar = bar
+	t.styleToolbar()
+	t.relayout()
+}
+
+// styleToolbar colors the toolbar buttons with the theme.
+func (t *Terminal) styleToolbar() {
+	if t.toolbar == nil {
+		return
+	}
+	buttons := t.toolbar.Get("children")
+	for i := 0; i < buttons.Length(); i++ {
+		bs := buttons.Index(i).Get("style")
+		bs.Set("color", t.theme.Foreground)
+		bs.Set("background", t.theme.Background)
+		bs.Set("borderTop", "1px solid "+t.theme.Selection)
+	}
+}
+
+func abs(f float64) float64 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
@@ -0,0 +1,159 @@
+package terminal
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	wideTail rune = -1     // the second cell of a wide character
+	zwj           = 0x200D // zero width joiner, which joins emoji
+	vs16          = 0xFE0F // variation selector for emoji presentation
+)
+
+// wideRanges are the East Asian wide and fullwidth characters and the
+// emoji that are drawn two cells wide.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F}, {0x231A, 0x231B}, {0x2329, 0x232A}, {0x23E9, 0x23EC},
+	{0x23F0, 0x23F0}, {0x23F3, 0x23F3}, {0x25FD, 0x25FE}, {0x2614, 0x2615},
+	{0x2648, 0x2653}, {0x267F, 0x267F}, {0x2693, 0x2693}, {0x26A1, 0x26A1},
+	{0x26AA, 0x26AB}, {0x26BD, 0x26BE}, {0x26C4, 0x26C5}, {0x26CE, 0x26CE},
+	{0x26D4, 0x26D4}, {0x26EA, 0x26EA}, {0x26F2, 0x26F3}, {0x26F5, 0x26F5},
+	{0x26FA, 0x26FA}, {0x26FD, 0x26FD}, {0x2705, 0x2705}, {0x270A, 0x270B},
+	{0x2728, 0x2728}, {0x274C, 0x274C}, {0x274E, 0x274E}, {0x2753, 0x2755},
+	{0x2757, 0x2757}, {0x2795, 0x2797}, {0x27B0, 0x27B0}, {0x27BF, 0x27BF},
+	{0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55}, {0x2E80, 0x303E},
+	{0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF}, {0xA000, 0xA4CF},
+	{0xA960, 0xA97F}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF}, {0xFE10, 0xFE19},
+	{0xFE30, 0xFE6F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x16FE0, 0x16FE4},
+	{0x17000, 0x18AFF}, {0x1B000, 0x1B2FF}, {0x1F004, 0x1F004}, {0x1F0CF, 0x1F0CF},
+	{0x1F18E, 0x1F18E}, {0x1F191, 0x1F19A}, {0x1F1E6, 0x1F1FF}, {0x1F200, 0x1F251},
+	{0x1F300, 0x1F3FA}, {0x1F400, 0x1F64F}, {0x1F680, 0x1F6FF}, {0x1F7E0, 0x1F7EB},
+	{0x1F90C, 0x1F9FF}, {0x1FA70, 0x1FAFF}, {0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+// runeWidth returns the number of cells a character takes.
+func runeWidth(r rune) int {
+	if isZeroWidth(r) {
+		return 0
+	}
+	i := sort.Search(len(wideRanges), func(i int) bool {
+		return wideRanges[i][1] >= r
+	})
+	if i < len(wideRanges) && wideRanges[i][0] <= r {
+		return 2
+	}
+	return 1
+}
+
+// isZeroWidth reports whether a character is drawn on top of the one
+// before it, such as a combining mark, a variation selector or an emoji
+// skin tone.
+func isZeroWidth(r rune) bool {
+	switch {
+	case r >= 0x1160 && r <= 0x11FF: // Hangul vowels and final consonants
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF: // emoji modifiers
+		return true
+	}
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc, unicode.Cf)
+}
+
+func isRegional(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// joins reports whether r belongs to the character that ends with last,
+// which is the first rune of a character when single is set. Combining
+// marks join any character, a rune after a zero width joiner joins the
+// emoji before it and two regional indicators make a flag.
+func joins(last rune, single bool, r rune) bool {
+	switch {
+	case r < ' ' || last < ' ':
+		return false
+	case isZeroWidth(r), last == zwj:
+		return true
+	}
+	return single && isRegional(last) && isRegional(r)
+}
+
+// joinsCell reports whether r belongs to the character at the cell before
+// col, and returns the column of that character.
+func joinsCell(line []cell, col int, r rune) (head int, ok bool) {
+	head = col - 1
+	if head > 0 && head < len(line) && line[head].ch == wideTail {
+		head--
+	}
+	if head < 0 || head >= len(line) {
+		return head, false
+	}
+	c := line[head]
+	last := c.ch
+	if c.comb != "" {
+		last, _ = utf8.DecodeLastRuneInString(c.comb)
+	}
+	return head, joins(last, c.comb == "", r)
+}
+
+// joinCell adds r to the character at head, where col is the column after
+// it. It returns the line and the column after the character, which moves
+// on when a variation selector makes the character wide.
+func joinCell(line []cell, head, col int, r rune) ([]cell, int) {
+	line[head].comb += string(r)
+	if r == vs16 && head+1 == col && runeWidth(line[head].ch) == 1 {
+		tail := cell{ch: wideTail, attr: line[head].attr}
+		if col < len(line) {
+			line[col] = tail
+		} else {
+			line = append(line, tail)
+		}
+		col++
+	}
+	return line, col
+}
+
+// graphemeLen returns the length in bytes of the first user-perceived
+// character of s, which may be several runes long.
+func graphemeLen(s string) int {
+	r, n := utf8.DecodeRuneInString(s)
+	if n == 0 {
+		return 0
+	}
+	last, single := r, true
+	for n < len(s) {
+		r, m := utf8.DecodeRuneInString(s[n:])
+		if !joins(last, single, r) {
+			break
+		}
+		last, single = r, false
+		n += m
+	}
+	return n
+}
+
+// lastGraphemeLen returns the length in bytes of the last user-perceived
+// character of s.
+func lastGraphemeLen(s string) int {
+	n := 0
+	for i := 0; i < len(s); i += n {
+		n = graphemeLen(s[i:])
+	}
+	return n
+}
+
+// graphemeCount returns the number of user-perceived characters in s.
+func graphemeCount(s string) int {
+	count := 0
+	for i := 0; i < len(s); i += graphemeLen(s[i:]) {
+		count++
+	}
+	return count
+}
+
+// utf16Len returns the length of s in UTF-16 code units, which is how
+// JavaScript counts string offsets.
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
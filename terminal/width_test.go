@@ -0,0 +1,169 @@
+package terminal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRuneWidth(t *testing.T) {
+	tests := []struct {
+		r     rune
+		width int
+	}{
+		{'a', 1},
+		{'é', 1},
+		{'→', 1},
+		{'中', 2},
+		{'ア', 2},
+		{'한', 2},
+		{'Ａ', 2}, // fullwidth A
+		{'ｱ', 1}, // halfwidth katakana
+		{'😀', 2},
+		{'🚀', 2},
+		{'⌚', 2},
+		{0x1F1FA, 2}, // regional indicator U
+		{0x0301, 0},  // combining acute accent
+		{0x200D, 0},  // zero width joiner
+		{0xFE0F, 0},  // variation selector 16
+		{0x1F3FD, 0}, // skin tone
+		{0x1161, 0},  // Hangul vowel
+		{0x20000, 2}, // CJK extension B
+	}
+	for _, tt := range tests {
+		if got := runeWidth(tt.r); got != tt.width {
+			t.Errorf("runeWidth(%U) = %d, want %d", tt.r, got, tt.width)
+		}
+	}
+}
+
+func TestGraphemes(t *testing.T) {
+	family := "\U0001F468\u200D\U0001F469\u200D\U0001F467\u200D\U0001F466"
+	tests := []struct {
+		s     string
+		first string // the first user-perceived character
+		last  string // the last one
+		count int
+	}{
+		{"", "", "", 0},
+		{"abc", "a", "c", 3},
+		{"中文", "中", "文", 2},
+		{"e\u0301x", "e\u0301", "x", 2},                                         // combining mark
+		{"xa\u0323\u0308", "x", "a\u0323\u0308", 2},                             // two combining marks
+		{"\U0001F44D\U0001F3FD!", "\U0001F44D\U0001F3FD", "!", 2},               // skin tone
+		{"\U0001F469\u200D\U0001F4BB ok", "\U0001F469\u200D\U0001F4BB", "k", 4}, // ZWJ sequence
+		{family, family, family, 1},
+		{"\u2764\uFE0F", "\u2764\uFE0F", "\u2764\uFE0F", 1}, // emoji presentation
+		{"🇺🇸🇬🇧", "🇺🇸", "🇬🇧", 2},                             // flags pair up
+		{"🇺🇸🇬", "🇺🇸", "🇬", 2},
+		{"\u0301a", "\u0301", "a", 2}, // nothing to combine with
+		{"a\nb", "a", "b", 3},
+	}
+	for _, tt := range tests {
+		if got := tt.s[:graphemeLen(tt.s)]; got != tt.first {
+			t.Errorf("graphemeLen(%q) gives %q, want %q", tt.s, got, tt.first)
+		}
+		if got := tt.s[len(tt.s)-lastGraphemeLen(tt.s):]; got != tt.last {
+			t.Errorf("lastGraphemeLen(%q) gives %q, want %q", tt.s, got, tt.last)
+		}
+		if got := graphemeCount(tt.s); got != tt.count {
+			t.Errorf("graphemeCount(%q) = %d, want %d", tt.s, got, tt.count)
+		}
+	}
+}
+
+func TestAppendStyledWidth(t *testing.T) {
+	tests := []struct {
+		s     string
+		cells string // one byte per cell: n narrow, w wide head, t wide tail
+	}{
+		{"ab", "nn"},
+		{"中a", "wtn"},
+		{"e\u0301", "n"},
+		{"\U0001F469\u200D\U0001F4BBx", "wtn"},
+		{"\u2764\uFE0F", "wt"}, // VS16 makes a narrow character wide
+		{"\U0001F44D\U0001F3FD", "wt"},
+		{"🇺🇸🇬🇧", "wtwt"},
+		{"\x1b[1m中\x1b[0m", "wt"},
+	}
+	for _, tt := range tests {
+		var got []byte
+		line := appendStyled(nil, tt.s)
+		for i, c := range line {
+			switch {
+			case c.ch == wideTail:
+				got = append(got, 't')
+			case i+1 < len(line) && line[i+1].ch == wideTail:
+				got = append(got, 'w')
+			default:
+				got = append(got, 'n')
+			}
+		}
+		if string(got) != tt.cells {
+			t.Errorf("appendStyled(%q) cells = %s, want %s", tt.s, got, tt.cells)
+		}
+		if text := cellText(line); text != stripEscapes(tt.s) {
+			t.Errorf("cellText(appendStyled(%q)) = %q", tt.s, text)
+		}
+	}
+}
+
+func TestUTF16Len(t *testing.T) {
+	tests := []struct {
+		s string
+		n int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"中", 1},
+		{"😀", 2},
+		{"\U0001F469\u200D\U0001F4BB", 5},
+	}
+	for _, tt := range tests {
+		if got := utf16Len(tt.s); got != tt.n {
+			t.Errorf("utf16Len(%q) = %d, want %d", tt.s, got, tt.n)
+		}
+	}
+}
+
+func TestWrapWide(t *testing.T) {
+	tests := []struct {
+		s    string
+		cols int
+		rows string // the wrapped rows, - is a wide tail
+	}{
+		{"abcd", 4, "abcd"},
+		{"abcde", 4, "abcd|e"},
+		{"ab中", 4, "ab中-"},
+		{"abc中d", 4, "abc|中-d"}, // a wide character at the wrap point
+		{"abc中中中", 4, "abc|中-中-|中-"},
+		{"中", 1, "中|-"}, // no room for the whole character
+	}
+	for _, tt := range tests {
+		line := appendStyled(nil, tt.s)
+		var rows [][]rune
+		w := wrapper{cols: tt.cols}
+		for j, c := range line {
+			r, x := w.place(isWideHead(line, j))
+			for len(rows) <= r {
+				rows = append(rows, []rune(strings.Repeat(".", tt.cols)))
+			}
+			if c.ch == wideTail {
+				c.ch = '-'
+			}
+			rows[r][x] = c.ch
+			if got := cellRow(line, j, tt.cols); got != r {
+				t.Errorf("cellRow(%q, %d, %d) = %d, want %d", tt.s, j, tt.cols, got, r)
+			}
+		}
+		var got []string
+		for _, row := range rows {
+			got = append(got, strings.TrimRight(string(row), "."))
+		}
+		if strings.Join(got, "|") != tt.rows {
+			t.Errorf("%q wrapped at %d = %s, want %s", tt.s, tt.cols, strings.Join(got, "|"), tt.rows)
+		}
+		if n := lineRows(line, tt.cols); n != len(rows) {
+			t.Errorf("lineRows(%q, %d) = %d, want %d", tt.s, tt.cols, n, len(rows))
+		}
+	}
+}